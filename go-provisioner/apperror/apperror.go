@@ -0,0 +1,170 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/gofiber/fiber/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Code is the machine-readable kind of an error, rendered next to its message
+type Code string
+
+const (
+	CodeValidationFailed    Code = "validation_failed"
+	CodeUnauthorized        Code = "unauthorized"
+	CodeForbidden           Code = "forbidden"
+	CodeNotFound            Code = "not_found"
+	CodeConflict            Code = "conflict"
+	CodePaymentFailed       Code = "payment_failed"
+	CodeTooManyRequests     Code = "too_many_requests"
+	CodeUpstreamUnavailable Code = "upstream_unavailable"
+	CodeInternal            Code = "internal"
+)
+
+var statusByCode = map[Code]int{
+	CodeValidationFailed:    fiber.StatusBadRequest,
+	CodeUnauthorized:        fiber.StatusUnauthorized,
+	CodeForbidden:           fiber.StatusForbidden,
+	CodeNotFound:            fiber.StatusNotFound,
+	CodeConflict:            fiber.StatusConflict,
+	CodePaymentFailed:       fiber.StatusPaymentRequired,
+	CodeTooManyRequests:     fiber.StatusTooManyRequests,
+	CodeUpstreamUnavailable: fiber.StatusBadGateway,
+	CodeInternal:            fiber.StatusInternalServerError,
+}
+
+// Error is an error with the http status and the code it is rendered with
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Exportable functions
+
+// New returns an error of code, rendered with the status of the code
+func New(code Code, message string) *Error {
+	return &Error{Status: statusOf(code), Code: code, Message: message}
+}
+
+// Newf is New with a formatted message
+func Newf(code Code, format string, args ...interface{}) *Error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// Wrap gives code to err, keeping its message
+func Wrap(code Code, err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Status: statusOf(code), Code: code, Message: err.Error(), Err: err}
+}
+
+// FromHTTPStatus returns the error of an upstream api that answered with
+// status. The upstream failures become upstream_unavailable, the others keep
+// their meaning for our caller.
+func FromHTTPStatus(status int, message string) *Error {
+	if status >= 500 || status == http.StatusTooManyRequests {
+		return New(CodeUpstreamUnavailable, message)
+	}
+	return New(codeOf(status), message)
+}
+
+// From classifies err: our errors are kept, the Kubernetes status errors and
+// the fiber errors are mapped to their code, and anything else is internal
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	appErr := &Error{}
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	statusErr := &apierrors.StatusError{}
+	if errors.As(err, &statusErr) {
+		return fromKubernetes(err)
+	}
+	fiberErr := &fiber.Error{}
+	if errors.As(err, &fiberErr) {
+		return &Error{Status: fiberErr.Code, Code: codeOf(fiberErr.Code), Message: fiberErr.Message, Err: err}
+	}
+	return Wrap(CodeInternal, err)
+}
+
+// Handler is the fiber error handler rendering every error returned by the
+// controllers and the middlewares as {"error": message, "code": code}
+func Handler(c *fiber.Ctx, err error) error {
+	e := From(err)
+	if e.Status >= fiber.StatusInternalServerError {
+		logger.FromContext(c.UserContext()).Error("request failed", "method", c.Method(), "path", c.Path(), "status", e.Status, "error", err)
+	}
+	return c.Status(e.Status).JSON(fiber.Map{
+		"error": e.Message,
+		"code":  e.Code,
+	})
+}
+
+// Local functions
+
+func statusOf(code Code) int {
+	if status, ok := statusByCode[code]; ok {
+		return status
+	}
+	return fiber.StatusInternalServerError
+}
+
+// codeOf returns the code of an http status
+func codeOf(status int) Code {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusPaymentRequired:
+		return CodePaymentFailed
+	case status == http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case status >= 400 && status < 500:
+		return CodeValidationFailed
+	default:
+		return CodeInternal
+	}
+}
+
+func fromKubernetes(err error) *Error {
+	code := CodeInternal
+	switch {
+	case apierrors.IsNotFound(err):
+		code = CodeNotFound
+	case apierrors.IsForbidden(err):
+		code = CodeForbidden
+	case apierrors.IsUnauthorized(err):
+		code = CodeUnauthorized
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		code = CodeConflict
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		code = CodeValidationFailed
+	case apierrors.IsServiceUnavailable(err), apierrors.IsServerTimeout(err),
+		apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err):
+		code = CodeUpstreamUnavailable
+	}
+	return Wrap(code, err)
+}
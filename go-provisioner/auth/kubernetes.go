@@ -1,41 +1,60 @@
 package auth
 
 import (
-	"log"
+	"os"
 
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 var MyClientSet *kubernetes.Clientset
+var MyConfig *rest.Config
 
-func CreateInClusterClient() {
-	config, err := rest.InClusterConfig()
+// CreateKubernetesClient creates the client from the kubeconfig file and
+// context given, or from the KUBECONFIG environment variable. Without any of
+// them the in-cluster config is used.
+func CreateKubernetesClient(kubeconfig string, context string) {
+	config, err := LoadConfig(kubeconfig, context)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal("cannot load the kubernetes config", "error", err)
 	}
+	config.Wrap(metrics.WrapTransport)
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal("cannot create the kubernetes client", "error", err)
 	}
 	MyClientSet = clientset
+	MyConfig = config
 }
 
-// del later
-func CreateOutClusterClient() {
+// LoadConfig returns the in-cluster config unless a kubeconfig file, the
+// KUBECONFIG environment variable or a context is given
+func LoadConfig(kubeconfig string, context string) (*rest.Config, error) {
+	if kubeconfig == "" && context == "" && os.Getenv("KUBECONFIG") == "" {
+		logger.Info("using the in-cluster kubernetes config")
+		return rest.InClusterConfig()
+	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", "/home/seif/Documents/kubernetes/configs/observability.yaml")
-	if err != nil {
-		panic(err.Error())
+	// the default rules read KUBECONFIG, then ~/.kube/config
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
 	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
 
-	// create the clientset
-	clientset, err := kubernetes.NewForConfig(config)
+	rawConfig, err := clientConfig.RawConfig()
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
-
-	MyClientSet = clientset
+	current := rawConfig.CurrentContext
+	if context != "" {
+		current = context
+	}
+	logger.Info("using a kubernetes context", "context", current)
+	return clientConfig.ClientConfig()
 }
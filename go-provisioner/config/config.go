@@ -0,0 +1,414 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	FolderConfig  = "config"
+	FolderSecrets = "secrets"
+
+	// a ConfigMap or Secret update touches several files, they are read
+	// together once the events stop
+	reloadDelay = time.Second
+)
+
+// variables holding a duration, checked at load time
+var durationVariables = []string{
+	"TRIAL_DURATION",
+	"TRIAL_WARNING_PERIOD",
+	"TRIAL_RETENTION",
+	"TRIAL_SWEEP_INTERVAL",
+	"RECONCILE_INTERVAL",
+	"RECONCILE_GRACE_PERIOD",
+	"GC_MIN_AGE",
+	"USAGE_SAMPLE_INTERVAL",
+	"KUBECONFIG_TOKEN_TTL",
+	"KUBECONFIG_TOKEN_MAX_TTL",
+	"LOGIN_WINDOW",
+	"LOGIN_LOCKOUT",
+	"LOGIN_MAX_LOCKOUT",
+	"LOGIN_USER_MAX_LOCKOUT",
+	"SHUTDOWN_TIMEOUT",
+}
+
+// variables holding a positive integer, checked at load time
+var intVariables = []string{
+	"LOGIN_MAX_ATTEMPTS",
+	"LOGIN_MAX_ATTEMPTS_PER_IP",
+	"LOGIN_MAX_ATTEMPTS_PER_USER",
+	"GC_MAX_DELETIONS",
+}
+
+// variables holding a number, checked at load time
+var floatVariables = []string{
+	"QUOTA_WARNING_PERCENT",
+	"TRACING_SAMPLE_RATIO",
+}
+
+var (
+	current atomic.Value
+
+	hooksMu sync.Mutex
+	hooks   []func()
+)
+
+// Exportable functions
+
+// Load reads the config and secrets folders, applies the environment
+// overrides and validates the result. The configuration is only replaced
+// when it is valid.
+func Load() error {
+	c, err := read()
+	if err != nil {
+		return err
+	}
+	err = c.validate()
+	if err != nil {
+		return err
+	}
+	current.Store(c)
+	return nil
+}
+
+// Get returns the current configuration, Load must have been called
+func Get() *Config {
+	c, _ := current.Load().(*Config)
+	if c == nil {
+		return &Config{values: map[string]map[string]string{}}
+	}
+	return c
+}
+
+// Lookup returns the raw value of a variable, the environment variable of the
+// same name wins over the file of folder
+func (c *Config) Lookup(folder string, name string) (string, bool) {
+	if value, ok := c.env[name]; ok {
+		return value, true
+	}
+	value, ok := c.values[folder][name]
+	return value, ok
+}
+
+// Duration returns the duration variable name of the config folder,
+// defaultValue when it is not set
+func (c *Config) Duration(name string, defaultValue time.Duration) time.Duration {
+	value, ok := c.Lookup(FolderConfig, name)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	// the value was checked by validate
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// Int returns the integer variable name of the config folder, defaultValue
+// when it is not set
+func (c *Config) Int(name string, defaultValue int) int {
+	value, ok := c.Lookup(FolderConfig, name)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+// Float returns the number variable name of the config folder, defaultValue
+// when it is not set
+func (c *Config) Float(name string, defaultValue float64) float64 {
+	value, ok := c.Lookup(FolderConfig, name)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return f
+}
+
+// OnReload registers a function called after each successful reload, for
+// the packages caching objects built from the configuration
+func OnReload(hook func()) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// Watch reloads the configuration when the files of the config or secrets
+// folders change. An invalid configuration is logged and the previous one is
+// kept. It blocks until ctx is done and should be run in its own goroutine.
+func Watch(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error("config: cannot watch the config folders", "error", err)
+		return
+	}
+	defer watcher.Close()
+
+	for _, folder := range []string{FolderConfig, FolderSecrets} {
+		err = watcher.Add(folderPath(folder))
+		if err != nil {
+			logger.Error("config: cannot watch a folder", "folder", folderPath(folder), "error", err)
+		}
+	}
+
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// mounted volumes swap a symlink, chmod events carry no change
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDelay, func() {
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+			})
+		case <-reload:
+			err = Load()
+			if err != nil {
+				logger.Error("config: keeping the previous configuration", "error", err)
+				continue
+			}
+			logger.Info("config: reloaded")
+			runHooks()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("config: watch error", "error", err)
+		}
+	}
+}
+
+// Local functions
+
+func read() (*Config, error) {
+	c := &Config{
+		values: map[string]map[string]string{},
+		env:    map[string]string{},
+	}
+	for _, folder := range []string{FolderConfig, FolderSecrets} {
+		values, err := readFolder(folderPath(folder))
+		if err != nil {
+			return nil, err
+		}
+		c.values[folder] = values
+	}
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && isVariableName(name) {
+			c.env[name] = value
+		}
+	}
+
+	c.RancherURL, _ = c.Lookup(FolderConfig, "RANCHER_URL")
+	c.RancherToken, _ = c.Lookup(FolderSecrets, "RANCHER_TOKEN")
+	c.ClusterId, _ = c.Lookup(FolderConfig, "CLUSTER_ID")
+	c.Clusters, _ = c.Lookup(FolderConfig, "CLUSTERS")
+	c.BillingURL, _ = c.Lookup(FolderConfig, "BILLING_URL")
+	c.BillingProjectPath, _ = c.Lookup(FolderConfig, "BILLING_PROJECT_PATH")
+	c.PaymeeURL, _ = c.Lookup(FolderConfig, "PAYMEE_URL")
+	c.PaymeeToken, _ = c.Lookup(FolderSecrets, "PAYMEE_TOKEN")
+	c.AdminToken, _ = c.Lookup(FolderSecrets, "ADMIN_TOKEN")
+	c.IntrospectionToken, _ = c.Lookup(FolderSecrets, "INTROSPECTION_TOKEN")
+	c.GithubClientId, _ = c.Lookup(FolderConfig, "GITHUB_CLIENT_ID")
+	c.GithubClientSecret, _ = c.Lookup(FolderSecrets, "GITHUB_CLIENT_SECRET")
+
+	c.LogLevel, _ = c.Lookup(FolderConfig, "LOG_LEVEL")
+	c.ProxyHeader, _ = c.Lookup(FolderConfig, "PROXY_HEADER")
+	c.TrustedProxies, _ = c.Lookup(FolderConfig, "TRUSTED_PROXIES")
+	c.TracingExporter, _ = c.Lookup(FolderConfig, "TRACING_EXPORTER")
+	c.AuditSink, _ = c.Lookup(FolderConfig, "AUDIT_SINK")
+	c.AuditFile, _ = c.Lookup(FolderConfig, "AUDIT_FILE")
+	c.TokensNamespace, _ = c.Lookup(FolderConfig, "TOKENS_NAMESPACE")
+	c.RegistriesNamespace, _ = c.Lookup(FolderConfig, "REGISTRIES_NAMESPACE")
+	c.TrialsNamespace, _ = c.Lookup(FolderConfig, "TRIALS_NAMESPACE")
+	c.LeaseNamespace, _ = c.Lookup(FolderConfig, "LEASE_NAMESPACE")
+	c.DefaultRegion, _ = c.Lookup(FolderConfig, "DEFAULT_REGION")
+	c.KubeAPIServer, _ = c.Lookup(FolderConfig, "KUBE_API_SERVER")
+	c.KubeAPICAData, _ = c.Lookup(FolderConfig, "KUBE_API_CA_DATA")
+	c.BaselinePolicies, _ = c.Lookup(FolderConfig, "BASELINE_POLICIES")
+	c.BaselinePodSecurityLevel, _ = c.Lookup(FolderConfig, "BASELINE_POD_SECURITY_LEVEL")
+	c.IngressNamespaces, _ = c.Lookup(FolderConfig, "INGRESS_NAMESPACES")
+	return c, nil
+}
+
+// readFolder returns the first line of every file of dir, a missing folder is
+// an empty one
+func readFolder(dir string) (map[string]string, error) {
+	values := map[string]string{}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		// skip the ..data folders of mounted volumes
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values[entry.Name()] = firstLine(b)
+	}
+	return values, nil
+}
+
+func firstLine(b []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	if scanner.Scan() {
+		return scanner.Text()
+	}
+	return ""
+}
+
+func (c *Config) validate() error {
+	errs := []string{}
+	for name, value := range map[string]string{
+		"RANCHER_URL": c.RancherURL,
+		"BILLING_URL": c.BillingURL,
+		"PAYMEE_URL":  c.PaymeeURL,
+	} {
+		if err := validateURL(value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if c.RancherToken == "" {
+		errs = append(errs, "RANCHER_TOKEN: required")
+	}
+	if c.PaymeeToken == "" {
+		errs = append(errs, "PAYMEE_TOKEN: required")
+	}
+	if c.ClusterId == "" && c.Clusters == "" {
+		errs = append(errs, "CLUSTER_ID or CLUSTERS: required")
+	}
+	for _, name := range durationVariables {
+		value, ok := c.Lookup(FolderConfig, name)
+		if !ok || value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	for _, name := range intVariables {
+		value, ok := c.Lookup(FolderConfig, name)
+		if !ok || value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			errs = append(errs, fmt.Sprintf("%s: %s is not a positive integer", name, value))
+		}
+	}
+	for _, name := range floatVariables {
+		value, ok := c.Lookup(FolderConfig, name)
+		if !ok || value == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s is not a number", name, value))
+		}
+	}
+	if _, err := logger.ParseLevel(c.LogLevel); c.LogLevel != "" && err != nil {
+		errs = append(errs, fmt.Sprintf("LOG_LEVEL: %v", err))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func validateURL(value string) error {
+	if value == "" {
+		return errors.New("required")
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s is not an http(s) url", value)
+	}
+	return nil
+}
+
+// folderPath returns the folder of the variables, CONFIG_DIR and SECRETS_DIR
+// move them from the working directory
+func folderPath(folder string) string {
+	envName := "CONFIG_DIR"
+	if folder == FolderSecrets {
+		envName = "SECRETS_DIR"
+	}
+	if dir := os.Getenv(envName); dir != "" {
+		return dir
+	}
+	return folder
+}
+
+// isVariableName keeps the upper case environment variables, the ones the
+// config and secrets files are named after
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func runHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+// Config is a snapshot of the config and secrets folders. The typed fields
+// are the variables checked at load time, the others are read with Lookup.
+type Config struct {
+	RancherURL   string
+	RancherToken string
+	ClusterId    string
+	Clusters     string
+	BillingURL   string
+	PaymeeURL    string
+	PaymeeToken  string
+	AdminToken   string
+
+	GithubClientId     string
+	GithubClientSecret string
+
+	// IntrospectionToken authenticates the services calling the token
+	// introspection
+	IntrospectionToken string
+
+	LogLevel                 string
+	ProxyHeader              string
+	TrustedProxies           string
+	TracingExporter          string
+	AuditSink                string
+	AuditFile                string
+	TokensNamespace          string
+	RegistriesNamespace      string
+	TrialsNamespace          string
+	LeaseNamespace           string
+	DefaultRegion            string
+	KubeAPIServer            string
+	KubeAPICAData            string
+	BaselinePolicies         string
+	BaselinePodSecurityLevel string
+	IngressNamespaces        string
+	// BillingProjectPath is the path of a project in the billing service,
+	// with {clusterId} and {projectId} placeholders
+	BillingProjectPath string
+
+	values map[string]map[string]string
+	env    map[string]string
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/audit"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/Creometry/dashboard/go-provisioner/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultAuditLimit = 100
+
+func GetProjectAudit(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	limit := defaultAuditLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return apperror.New(apperror.CodeValidationFailed, "limit must be a positive integer")
+		}
+	}
+
+	err = requireProjectOwner(c, prId, "only the project owners can read the audit log")
+	if err != nil {
+		return err
+	}
+
+	data, err := audit.List(prId, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+// requireProjectOwner fails with message unless the caller owns projectId
+func requireProjectOwner(c *fiber.Ctx, projectId string, message string) error {
+	userId, err := getCaller(c)
+	if err != nil {
+		return err
+	}
+	owner, err := project.IsProjectOwner(c.UserContext(), userId, projectId)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		return apperror.New(apperror.CodeForbidden, message)
+	}
+	return nil
+}
+
+// requireProjectMember fails with message unless the caller is a member of
+// projectId
+func requireProjectMember(c *fiber.Ctx, projectId string, message string) error {
+	userId, err := getCaller(c)
+	if err != nil {
+		return err
+	}
+	member, err := project.IsProjectMember(c.UserContext(), userId, projectId)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return apperror.New(apperror.CodeForbidden, message)
+	}
+	return nil
+}
+
+// getCaller returns the user behind the api token or the Rancher token of the request
+func getCaller(c *fiber.Ctx) (string, error) {
+	if t, ok := middleware.ApiToken(c); ok {
+		return t.UserId, nil
+	}
+	rancherToken := middleware.BearerToken(c)
+	if rancherToken == "" {
+		return "", apperror.New(apperror.CodeUnauthorized, "a Rancher token is required")
+	}
+	return project.GetCurrentUser(c.UserContext(), rancherToken)
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/internal/catalog"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ListTemplates(c *fiber.Ctx) error {
+	data, err := catalog.ListTemplates()
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
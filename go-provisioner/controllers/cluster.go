@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ListClusters(c *fiber.Ctx) error {
+	clusters, err := cluster.List()
+	if err != nil {
+		return err
+	}
+	data := []cluster.RespDataCluster{}
+	for _, cl := range clusters {
+		data = append(data, cl.ToResp())
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+// PreviewPlacement returns the cluster a new project would be placed on
+func PreviewPlacement(c *fiber.Ctx) error {
+	plan := c.Query("plan")
+	if plan == "" {
+		return apperror.New(apperror.CodeValidationFailed, "plan is required")
+	}
+	data, err := project.PlaceProject(c.UserContext(), plan, c.Query("region"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
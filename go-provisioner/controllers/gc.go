@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/gofiber/fiber/v2"
+)
+
+func CollectGarbage(c *fiber.Ctx) error {
+	reqData := new(project.ReqDataGC)
+	// an empty body runs the collection with the defaults
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(reqData); err != nil {
+			return apperror.Wrap(apperror.CodeValidationFailed, err)
+		}
+	}
+	if err := reqData.Validate(); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	data, err := project.CollectGarbage(c.UserContext(), *reqData)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/Creometry/dashboard/go-provisioner/internal/usage"
+	"github.com/gofiber/fiber/v2"
+)
+
+func SuspendProject(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	data, err := project.SuspendProject(c.UserContext(), prId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func ReactivateProject(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	data, err := project.ReactivateProject(c.UserContext(), prId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func ConvertTrialProject(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectOwner(c, prId, "only the project owners can convert a trial")
+	if err != nil {
+		return err
+	}
+	reqData := new(project.ReqDataConvertTrial)
+	if err := c.BodyParser(reqData); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	if err := reqData.Validate(); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	data, err := project.ConvertTrialProject(c.UserContext(), prId, *reqData)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func GetProjectUsage(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectMember(c, prId, "only the project members can read the usage")
+	if err != nil {
+		return err
+	}
+	data, err := usage.GetUsage(c.UserContext(), prId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func GetProjectQuota(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectMember(c, prId, "only the project members can read the quota")
+	if err != nil {
+		return err
+	}
+	data, err := project.GetProjectQuota(c.UserContext(), prId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func ListNamespaces(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectMember(c, prId, "only the project members can list the namespaces")
+	if err != nil {
+		return err
+	}
+	data, err := project.ListNamespaces(c.UserContext(), prId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"namespaces": data,
+	})
+}
+
+func AddNamespace(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectOwner(c, prId, "only the project owners can manage the namespaces")
+	if err != nil {
+		return err
+	}
+	reqData := new(project.ReqDataNamespace)
+	if err := c.BodyParser(reqData); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	if reqData.Name == "" {
+		return apperror.New(apperror.CodeValidationFailed, "name is required")
+	}
+	nsName, err := project.AddNamespace(c.UserContext(), prId, reqData.Name)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"namespace": nsName,
+	})
+}
+
+func DeleteNamespace(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectOwner(c, prId, "only the project owners can manage the namespaces")
+	if err != nil {
+		return err
+	}
+	err = project.DeleteNamespace(c.UserContext(), prId, c.Params("namespace"))
+	if err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func GenerateProjectKubeConfig(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	reqData := new(project.ReqDataProjectKubeconfig)
+	if err := c.BodyParser(reqData); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	if reqData.Token == "" {
+		return apperror.New(apperror.CodeValidationFailed, "token is required")
+	}
+	data, err := project.GetProjectKubeConfig(c.UserContext(), prId, *reqData)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func ListRegistries(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectOwner(c, prId, "only the project owners can manage the registries")
+	if err != nil {
+		return err
+	}
+	data, err := project.ListRegistries(c.UserContext(), prId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+// SetRegistry registers a registry on POST and rotates its credentials on PUT,
+// only PUT overwrites an existing registry
+func SetRegistry(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectOwner(c, prId, "only the project owners can manage the registries")
+	if err != nil {
+		return err
+	}
+	reqData := new(project.ReqDataRegistry)
+	if err := c.BodyParser(reqData); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	if name := c.Params("name"); name != "" {
+		reqData.Name = name
+	}
+	if err := reqData.Validate(); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	data, err := project.SetRegistry(c.UserContext(), prId, *reqData, c.Method() == fiber.MethodPut)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func DeleteRegistry(c *fiber.Ctx) error {
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	err = requireProjectOwner(c, prId, "only the project owners can manage the registries")
+	if err != nil {
+		return err
+	}
+	err = project.DeleteRegistry(c.UserContext(), prId, c.Params("name"))
+	if err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newFakeRancher serves the caller u-intruder and the project c-abcde:p-abcde
+// owned by u-owner, without any role binding
+func newFakeRancher(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v3/users" && r.URL.Query().Get("me") == "true":
+			w.Write([]byte(`{"data":[{"id":"u-intruder","username":"intruder"}]}`))
+		case r.URL.Path == "/v3/projects/c-abcde:p-abcde":
+			w.Write([]byte(`{"id":"c-abcde:p-abcde","annotations":{"creometry.com/owner":"u-owner"}}`))
+		case r.URL.Path == "/v3/projectroletemplatebindings":
+			w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Errorf("unexpected Rancher request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// loadTestConfig points the configuration at rancherURL, the other upstreams
+// are never called
+func loadTestConfig(t *testing.T, rancherURL string) {
+	t.Helper()
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	t.Setenv("SECRETS_DIR", t.TempDir())
+	t.Setenv("RANCHER_URL", rancherURL)
+	t.Setenv("RANCHER_TOKEN", "token-abcde:secret")
+	t.Setenv("BILLING_URL", "http://billing.invalid")
+	t.Setenv("PAYMEE_URL", "http://paymee.invalid")
+	t.Setenv("PAYMEE_TOKEN", "paymee-token")
+	t.Setenv("CLUSTER_ID", "c-abcde")
+	if err := config.Load(); err != nil {
+		t.Fatalf("cannot load the test configuration: %v", err)
+	}
+}
+
+func TestProjectRoutesRejectNonMembers(t *testing.T) {
+	logger.SetOutput(io.Discard)
+	loadTestConfig(t, newFakeRancher(t).URL)
+
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler})
+	app.Get("/projects/:projectId/usage", GetProjectUsage)
+	app.Get("/projects/:projectId/quota", GetProjectQuota)
+	app.Get("/projects/:projectId/namespaces", ListNamespaces)
+	app.Post("/projects/:projectId/namespaces", AddNamespace)
+	app.Delete("/projects/:projectId/namespaces/:namespace", DeleteNamespace)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "GET", path: "/projects/p-abcde/usage"},
+		{method: "GET", path: "/projects/p-abcde/quota"},
+		{method: "GET", path: "/projects/p-abcde/namespaces"},
+		{method: "POST", path: "/projects/p-abcde/namespaces", body: `{"name":"extra"}`},
+		{method: "DELETE", path: "/projects/p-abcde/namespaces/p-abcde-extra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer token-intruder:secret")
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("status is %d, want %d", resp.StatusCode, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -1,12 +1,11 @@
 package controllers
 
 import (
-	"fmt"
-	"strings"
-
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
 	"github.com/Creometry/dashboard/go-provisioner/internal/project"
 	"github.com/Creometry/dashboard/go-provisioner/internal/team"
-	"github.com/Creometry/dashboard/go-provisioner/utils"
+	"github.com/Creometry/dashboard/go-provisioner/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -14,25 +13,23 @@ func ProvisionProject(c *fiber.Ctx) error {
 	// parse the request body
 	reqData := new(project.ReqData)
 	if err := c.BodyParser(reqData); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
 	}
 	// check if the request body is valid
 	if err := reqData.Validate(); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
 	}
 
-	data, err := project.ProvisionProject(*reqData)
+	data, err := project.ProvisionProject(c.UserContext(), *reqData)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
+	c.Locals(middleware.LocalAuditProjectId, data.ProjectId)
 	return c.JSON(fiber.Map{
 		"projectId": data.ProjectId,
+		"namespace": data.Namespace,
+		"template":  data.Template,
+		"placement": data.Placement,
 	})
 }
 
@@ -40,21 +37,15 @@ func GenerateKubeConfig(c *fiber.Ctx) error {
 	// get the token from the body
 	reqData := new(project.ReqDataKubeconfig)
 	if err := c.BodyParser(reqData); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
 	}
 	// check if the request body is valid
 	if reqData.Token == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "token is required",
-		})
+		return apperror.New(apperror.CodeValidationFailed, "token is required")
 	}
-	data, err := project.GetKubeConfig(reqData.Token)
+	data, err := project.GetKubeConfig(c.UserContext(), reqData.Token, reqData.ClusterId)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"config": data,
@@ -64,33 +55,19 @@ func GenerateKubeConfig(c *fiber.Ctx) error {
 func ListTeamMembers(c *fiber.Ctx) error {
 	projectId := c.Params("projectId")
 	if projectId == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "projectId is required",
-		})
+		return apperror.New(apperror.CodeValidationFailed, "projectId is required")
 	}
 	// if projectId contains ':' then list team members without change
-	var prId string
-	if strings.Contains(projectId, ":") {
-		prId = projectId
-	} else {
-		clusterId, err := utils.GetVariable("config", "CLUSTER_ID")
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-		prId = fmt.Sprintf("%s:%s", clusterId, projectId)
-	}
-	data, err := team.ListTeamMembers(prId)
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
+	}
+	data, err := team.ListTeamMembers(c.UserContext(), prId)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	if data == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "no members found or invalid projectId",
-		})
+		return apperror.New(apperror.CodeNotFound, "no members found or invalid projectId")
 	}
 	return c.JSON(fiber.Map{
 		"members": data,
@@ -103,29 +80,17 @@ func AddTeamMember(c *fiber.Ctx) error {
 	userId := c.Params("userId")
 
 	if projectId == "" || userId == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "projectId and userId are required",
-		})
+		return apperror.New(apperror.CodeValidationFailed, "projectId and userId are required")
 	}
 
-	var prId string
-	if strings.Contains(projectId, ":") {
-		prId = projectId
-	} else {
-		clusterId, err := utils.GetVariable("config", "CLUSTER_ID")
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-		prId = fmt.Sprintf("%s:%s", clusterId, projectId)
+	prId, err := getProjectId(c)
+	if err != nil {
+		return err
 	}
 
-	data, err := project.AddUserToProject(userId, prId)
+	data, err := project.AddUserToProject(c.UserContext(), userId, prId)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -137,21 +102,15 @@ func Login(c *fiber.Ctx) error {
 	// get the token from the body
 	reqData := new(project.ReqDataLogin)
 	if err := c.BodyParser(reqData); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
 	}
 	// check if the request body is valid
 	if err := reqData.Validate(); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
 	}
-	id, token, uuid, err := project.Login(reqData.Username, reqData.Password)
+	id, token, uuid, err := project.Login(c.UserContext(), reqData.Username, reqData.Password)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"token":  token,
@@ -164,27 +123,19 @@ func Register(c *fiber.Ctx) error {
 	// get the token from the body
 	reqData := new(project.ReqDataRegister)
 	if err := c.BodyParser(reqData); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
 	}
 	// check if the request body is valid
 	if reqData.Username == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "username is required",
-		})
+		return apperror.New(apperror.CodeValidationFailed, "username is required")
 	}
-	id, token, password, uuid, err := project.Register(reqData.Username)
+	id, token, password, uuid, err := project.Register(c.UserContext(), reqData.Username)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	if token == "" || id == "" || password == "" || uuid == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "error registering user",
-		})
+		return apperror.New(apperror.CodeUpstreamUnavailable, "error registering user")
 	}
 
 	return c.JSON(fiber.Map{
@@ -194,3 +145,16 @@ func Register(c *fiber.Ctx) error {
 		"uuid":     uuid,
 	})
 }
+
+// getProjectId returns the full id of the projectId route parameter, and
+// checks it against the project the api token is restricted to
+func getProjectId(c *fiber.Ctx) (string, error) {
+	prId, err := cluster.FullProjectId(c.Params("projectId"))
+	if err != nil {
+		return "", err
+	}
+	if t, ok := middleware.ApiToken(c); ok && t.ProjectId != "" && t.ProjectId != prId {
+		return "", apperror.Newf(apperror.CodeForbidden, "api token is restricted to project %s", t.ProjectId)
+	}
+	return prId, nil
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/Creometry/dashboard/go-provisioner/internal/token"
+	"github.com/Creometry/dashboard/go-provisioner/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func CreateApiToken(c *fiber.Ctx) error {
+	userId, err := getTokenOwner(c)
+	if err != nil {
+		return err
+	}
+	reqData := new(token.ReqDataCreateToken)
+	if err := c.BodyParser(reqData); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	if err := reqData.Validate(); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	if reqData.ProjectId != "" {
+		reqData.ProjectId, err = cluster.FullProjectId(reqData.ProjectId)
+		if err != nil {
+			return err
+		}
+		member, err := project.IsProjectMember(c.UserContext(), userId, reqData.ProjectId)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return apperror.New(apperror.CodeForbidden, "only the project members can create tokens for the project")
+		}
+	}
+	data, err := token.CreateToken(c.UserContext(), userId, *reqData)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func ListApiTokens(c *fiber.Ctx) error {
+	userId, err := getTokenOwner(c)
+	if err != nil {
+		return err
+	}
+	data, err := token.ListTokens(c.UserContext(), userId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+func RevokeApiToken(c *fiber.Ctx) error {
+	userId, err := getTokenOwner(c)
+	if err != nil {
+		return err
+	}
+	err = token.RevokeToken(c.UserContext(), userId, c.Params("tokenId"))
+	if err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// IntrospectApiToken lets the other services verify an api token
+func IntrospectApiToken(c *fiber.Ctx) error {
+	reqData := new(token.ReqDataIntrospect)
+	if err := c.BodyParser(reqData); err != nil {
+		return apperror.Wrap(apperror.CodeValidationFailed, err)
+	}
+	t, err := token.Verify(c.UserContext(), reqData.Token)
+	if err != nil {
+		return c.JSON(token.RespDataIntrospect{Active: false})
+	}
+	return c.JSON(token.RespDataIntrospect{
+		Active:    true,
+		UserId:    t.UserId,
+		Scope:     t.Scope,
+		ProjectId: t.ProjectId,
+	})
+}
+
+// getTokenOwner identifies the user from its Rancher token, api tokens cannot
+// be used to manage api tokens
+func getTokenOwner(c *fiber.Ctx) (string, error) {
+	if _, ok := middleware.ApiToken(c); ok {
+		return "", apperror.New(apperror.CodeUnauthorized, "api tokens cannot be used to manage api tokens")
+	}
+	rancherToken := middleware.BearerToken(c)
+	if rancherToken == "" {
+		return "", apperror.New(apperror.CodeUnauthorized, "a Rancher token is required")
+	}
+	return project.GetCurrentUser(c.UserContext(), rancherToken)
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+)
+
+const (
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+
+	defaultAuditFile = "audit.log"
+)
+
+var (
+	sinkOnce sync.Once
+	sink     Sink
+	sinkErr  error
+)
+
+// Exportable functions
+
+// Record appends an event to the sink chosen by AUDIT_SINK. A failing sink
+// is logged, it never fails the audited call.
+func Record(e Event) {
+	s, err := getSink()
+	if err == nil {
+		err = s.Write(e)
+	}
+	if err != nil {
+		logger.Error("audit: cannot record an event", "action", e.Action, "actor", e.Actor, "requestId", e.RequestId, "error", err)
+	}
+}
+
+// List returns the last limit events of a project
+func List(projectId string, limit int) ([]Event, error) {
+	s, err := getSink()
+	if err != nil {
+		return nil, err
+	}
+	reader, ok := s.(Reader)
+	if !ok {
+		return nil, errors.New("the audit sink cannot be read back")
+	}
+	return reader.Read(projectId, limit)
+}
+
+// Close flushes and closes the sink
+func Close() error {
+	if sink == nil {
+		return nil
+	}
+	return sink.Close()
+}
+
+// Local functions
+
+func getSink() (Sink, error) {
+	sinkOnce.Do(func() {
+		sink, sinkErr = newSink()
+	})
+	return sink, sinkErr
+}
+
+func newSink() (Sink, error) {
+	kind := config.Get().AuditSink
+	if kind == "" {
+		kind = SinkFile
+	}
+	switch kind {
+	case SinkFile:
+		path := config.Get().AuditFile
+		if path == "" {
+			path = defaultAuditFile
+		}
+		return newFileSink(path)
+	case SinkStdout:
+		return newStdoutSink(), nil
+	default:
+		return nil, fmt.Errorf("unknown AUDIT_SINK %s", kind)
+	}
+}
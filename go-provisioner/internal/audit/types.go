@@ -0,0 +1,37 @@
+package audit
+
+import "time"
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	ActorAnonymous = "anonymous"
+)
+
+// Event is one mutating call, written as a single json line
+type Event struct {
+	Time       time.Time `json:"time"`
+	RequestId  string    `json:"requestId"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	ProjectId  string    `json:"projectId,omitempty"`
+	TargetUser string    `json:"targetUser,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Result     string    `json:"result"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Sink is a backend the events are appended to
+type Sink interface {
+	Write(e Event) error
+	Close() error
+}
+
+// Reader is implemented by the sinks the events can be read back from
+type Reader interface {
+	// Read returns the last limit events of a project, the most recent first
+	Read(projectId string, limit int) ([]Event, error)
+}
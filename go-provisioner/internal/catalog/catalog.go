@@ -0,0 +1,233 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+)
+
+// every template is a folder holding a template.yaml describing it and a
+// manifests.yaml rendered with text/template
+//
+//go:embed templates
+var templatesFS embed.FS
+
+// Exportable functions
+
+// ListTemplates returns the catalog sorted by id
+func ListTemplates() ([]Template, error) {
+	entries, err := templatesFS.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	res := []Template{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t, err := GetTemplate(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+	return res, nil
+}
+
+func GetTemplate(id string) (Template, error) {
+	b, err := templatesFS.ReadFile(path.Join("templates", id, "template.yaml"))
+	if err != nil {
+		return Template{}, apperror.Newf(apperror.CodeNotFound, "template %s not found", id)
+	}
+	t := Template{}
+	err = yaml.Unmarshal(b, &t)
+	if err != nil {
+		return Template{}, fmt.Errorf("invalid template %s: %w", id, err)
+	}
+	t.Id = id
+	return t, nil
+}
+
+// Validate checks that the template exists and that params holds every
+// required parameter with a value of the right type
+func Validate(id string, params map[string]string) error {
+	t, err := GetTemplate(id)
+	if err != nil {
+		return err
+	}
+	_, err = resolveParams(t, params)
+	return err
+}
+
+// Apply renders the template with params and creates its objects in
+// namespace of the cluster clusterId. An object that fails does not stop the
+// others, the outcome of each object is reported in the results.
+func Apply(ctx context.Context, id string, params map[string]string, namespace string, clusterId string) ([]ObjectResult, error) {
+	objects, err := render(id, params, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := cluster.Config(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	clientSet, err := cluster.ClientSet(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientSet.Discovery()))
+
+	results := []ObjectResult{}
+	for _, obj := range objects {
+		result := ObjectResult{
+			Kind: obj.GetKind(),
+			Name: obj.GetName(),
+		}
+		err = applyObject(ctx, dynamicClient, mapper, obj, namespace)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Local functions
+
+func resolveParams(t Template, params map[string]string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, p := range t.Parameters {
+		value, ok := params[p.Name]
+		if !ok || value == "" {
+			if p.Required {
+				return nil, apperror.Newf(apperror.CodeValidationFailed, "parameter %s is required by template %s", p.Name, t.Id)
+			}
+			value = p.Default
+		}
+		// values are rendered inside yaml, a line break could inject fields
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, apperror.Newf(apperror.CodeValidationFailed, "parameter %s of template %s must be a single line", p.Name, t.Id)
+		}
+		if p.Type == ParamTypeInteger {
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, apperror.Newf(apperror.CodeValidationFailed, "parameter %s of template %s must be an integer", p.Name, t.Id)
+			}
+		}
+		res[p.Name] = value
+	}
+	for name := range params {
+		if _, ok := res[name]; !ok {
+			return nil, apperror.Newf(apperror.CodeValidationFailed, "unknown parameter %s for template %s", name, t.Id)
+		}
+	}
+	return res, nil
+}
+
+func render(id string, params map[string]string, namespace string) ([]*unstructured.Unstructured, error) {
+	t, err := GetTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := resolveParams(t, params)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := templatesFS.ReadFile(path.Join("templates", id, "manifests.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := template.New(id).Option("missingkey=error").Funcs(template.FuncMap{
+		"random": randomString,
+	}).Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	err = tpl.Execute(buf, renderData{Namespace: namespace, Params: resolved})
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(buf, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err = decoder.Decode(&obj.Object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifests in template %s: %w", id, err)
+		}
+		// skip empty documents
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func applyObject(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured, namespace string) error {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return apperror.Newf(apperror.CodeValidationFailed, "%s is not namespaced, templates can only create namespaced objects", gvk.Kind)
+	}
+
+	obj.SetNamespace(namespace)
+	_, err = client.Resource(mapping.Resource).Namespace(namespace).Create(ctx, obj, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return apperror.Newf(apperror.CodeConflict, "%s %s already exists", gvk.Kind, obj.GetName())
+	}
+	return err
+}
+
+func randomString(n int) (string, error) {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[idx.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,36 @@
+package catalog
+
+const (
+	ParamTypeString  = "string"
+	ParamTypeInteger = "integer"
+)
+
+type Template struct {
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  []Parameter `json:"parameters"`
+}
+
+type Parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Type is string when empty, integers are rendered unquoted
+	Type     string `json:"type,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// ObjectResult is the outcome of applying one object of a template
+type ObjectResult struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// renderData is what the manifests of a template are rendered with
+type renderData struct {
+	Namespace string
+	Params    map[string]string
+}
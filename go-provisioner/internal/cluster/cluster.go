@@ -0,0 +1,248 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const defaultRegion = "default"
+
+type client struct {
+	config    *rest.Config
+	clientSet kubernetes.Interface
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]client{}
+)
+
+func init() {
+	// the clients may hold a rotated token or a changed kubeconfig path
+	config.OnReload(resetClients)
+}
+
+// Exportable functions
+
+// List returns the clusters of the CLUSTERS variable, a json array of
+// Cluster. Without it the provisioner only knows the cluster of CLUSTER_ID,
+// reached with the in-cluster client.
+func List() ([]Cluster, error) {
+	conf := config.Get()
+	if value := conf.Clusters; value != "" {
+		clusters := []Cluster{}
+		err := json.Unmarshal([]byte(value), &clusters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLUSTERS: %w", err)
+		}
+		if len(clusters) == 0 {
+			return nil, fmt.Errorf("invalid CLUSTERS: no cluster")
+		}
+		for i := range clusters {
+			if clusters[i].RancherClusterId == "" {
+				return nil, fmt.Errorf("invalid CLUSTERS: cluster %s has no rancherClusterId", clusters[i].Id)
+			}
+			if clusters[i].Id == "" {
+				clusters[i].Id = clusters[i].RancherClusterId
+			}
+		}
+		return clusters, nil
+	}
+
+	if conf.ClusterId == "" {
+		return nil, fmt.Errorf("CLUSTER_ID is not configured")
+	}
+	return []Cluster{{
+		Id:               conf.ClusterId,
+		Region:           DefaultRegion(),
+		RancherClusterId: conf.ClusterId,
+	}}, nil
+}
+
+// Get returns the cluster with the Rancher cluster id clusterId
+func Get(clusterId string) (Cluster, error) {
+	clusters, err := List()
+	if err != nil {
+		return Cluster{}, err
+	}
+	for _, c := range clusters {
+		if c.RancherClusterId == clusterId {
+			return c, nil
+		}
+	}
+	return Cluster{}, apperror.Newf(apperror.CodeNotFound, "unknown cluster %s", clusterId)
+}
+
+// ForProject returns the cluster of a "clusterId:projectId" project id
+func ForProject(projectId string) (Cluster, error) {
+	parts := strings.Split(projectId, ":")
+	if len(parts) != 2 {
+		return Cluster{}, apperror.Newf(apperror.CodeValidationFailed, "invalid projectId %s", projectId)
+	}
+	return Get(parts[0])
+}
+
+// FullProjectId prefixes projectId with the id of the default cluster unless
+// it already has the "clusterId:projectId" form
+func FullProjectId(projectId string) (string, error) {
+	if strings.Contains(projectId, ":") {
+		if _, err := ForProject(projectId); err != nil {
+			return "", err
+		}
+		return projectId, nil
+	}
+	c, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", c.RancherClusterId, projectId), nil
+}
+
+// InRegion returns the clusters of region, DEFAULT_REGION when region is empty
+func InRegion(region string) ([]Cluster, error) {
+	if region == "" {
+		region = DefaultRegion()
+	}
+	clusters, err := List()
+	if err != nil {
+		return nil, err
+	}
+	res := []Cluster{}
+	for _, c := range clusters {
+		if c.Region == region {
+			res = append(res, c)
+		}
+	}
+	if len(res) == 0 {
+		return nil, apperror.Newf(apperror.CodeNotFound, "no cluster in region %s", region)
+	}
+	return res, nil
+}
+
+// Default returns the cluster of the project ids sent without a cluster
+// prefix, the first cluster of DEFAULT_REGION
+func Default() (Cluster, error) {
+	clusters, err := InRegion("")
+	if err != nil {
+		return Cluster{}, err
+	}
+	return clusters[0], nil
+}
+
+// ClientSet returns the client of the cluster with the Rancher cluster id
+// clusterId, clients are created on first use
+func ClientSet(clusterId string) (kubernetes.Interface, error) {
+	cl, err := getClient(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	return cl.clientSet, nil
+}
+
+// SetClientSet replaces the client of the cluster with the Rancher cluster id
+// clusterId until the next reload, the tests use it to install a fake one
+func SetClientSet(clusterId string, clientSet kubernetes.Interface) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients[clusterId] = client{clientSet: clientSet}
+}
+
+// Config returns the rest config of the cluster with the Rancher cluster id
+// clusterId
+func Config(clusterId string) (*rest.Config, error) {
+	cl, err := getClient(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	return cl.config, nil
+}
+
+// DefaultRegion returns DEFAULT_REGION, "default" when it is not set
+func DefaultRegion() string {
+	region := config.Get().DefaultRegion
+	if region == "" {
+		return defaultRegion
+	}
+	return region
+}
+
+// ToResp hides how the cluster is reached
+func (c Cluster) ToResp() RespDataCluster {
+	return RespDataCluster{
+		Id:               c.Id,
+		Region:           c.Region,
+		RancherClusterId: c.RancherClusterId,
+	}
+}
+
+// Local functions
+
+func getClient(clusterId string) (client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if cl, ok := clients[clusterId]; ok {
+		return cl, nil
+	}
+
+	c, err := Get(clusterId)
+	if err != nil {
+		return client{}, err
+	}
+	config, err := restConfig(c)
+	if err != nil {
+		return client{}, err
+	}
+
+	cl := client{config: config}
+	if config == auth.MyConfig {
+		cl.clientSet = auth.MyClientSet
+	} else {
+		cl.clientSet, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return client{}, err
+		}
+	}
+	clients[clusterId] = cl
+	return cl, nil
+}
+
+func resetClients() {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients = map[string]client{}
+}
+
+func restConfig(c Cluster) (*rest.Config, error) {
+	if c.KubeConfig != "" {
+		restConf, err := clientcmd.BuildConfigFromFlags("", c.KubeConfig)
+		if err != nil {
+			return nil, err
+		}
+		restConf.Wrap(metrics.WrapTransport)
+		return restConf, nil
+	}
+	if c.RancherProxy {
+		conf := config.Get()
+		restConf := &rest.Config{
+			Host:        fmt.Sprintf("%s/k8s/clusters/%s", conf.RancherURL, c.RancherClusterId),
+			BearerToken: conf.RancherToken,
+		}
+		restConf.Wrap(metrics.WrapTransport)
+		return restConf, nil
+	}
+	if auth.MyConfig == nil {
+		return nil, fmt.Errorf("no client for cluster %s", c.Id)
+	}
+	return auth.MyConfig, nil
+}
@@ -0,0 +1,22 @@
+package cluster
+
+// Cluster is a Kubernetes cluster projects can be provisioned on. The
+// provisioner reaches it through KubeConfig when set, through the Rancher
+// proxy when RancherProxy is set, and with its in-cluster client otherwise.
+// ApiServer and ApiCAData are written in the kubeconfigs issued to users, they
+// default to KUBE_API_SERVER and KUBE_API_CA_DATA.
+type Cluster struct {
+	Id               string `json:"id"`
+	Region           string `json:"region"`
+	RancherClusterId string `json:"rancherClusterId"`
+	KubeConfig       string `json:"kubeconfig,omitempty"`
+	RancherProxy     bool   `json:"rancherProxy,omitempty"`
+	ApiServer        string `json:"apiServer,omitempty"`
+	ApiCAData        string `json:"apiCAData,omitempty"`
+}
+
+type RespDataCluster struct {
+	Id               string `json:"id"`
+	Region           string `json:"region"`
+	RancherClusterId string `json:"rancherClusterId"`
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+)
+
+const (
+	// the results are cached so that probes and dashboards polling the
+	// endpoints do not hammer the dependencies
+	cacheTTL     = 10 * time.Second
+	probeTimeout = 3 * time.Second
+)
+
+type check struct {
+	name     string
+	critical bool
+	probe    func(ctx context.Context) error
+
+	mu     sync.Mutex
+	status Status
+}
+
+// paymee and billing are only needed by the paid provisionings and the
+// usage push, which keeps its records until billing is back. Their outage
+// degrades the service instead of taking every pod out of the endpoints.
+var checks = []*check{
+	{name: "kubernetes", critical: true, probe: probeKubernetes},
+	{name: "rancher", critical: true, probe: probeRancher},
+	{name: "paymee", critical: false, probe: probePaymee},
+	{name: "billing", critical: false, probe: probeBilling},
+}
+
+// Exportable functions
+
+// Check returns the status of every dependency, probing again the ones whose
+// cached result is older than cacheTTL
+func Check() Report {
+	report := Report{Ready: true, Checks: make([]Status, len(checks))}
+	wg := sync.WaitGroup{}
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			report.Checks[i] = c.run()
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, s := range report.Checks {
+		if s.Healthy {
+			continue
+		}
+		if s.Critical {
+			report.Ready = false
+		} else {
+			report.Degraded = true
+		}
+	}
+	return report
+}
+
+// Local functions
+
+// run probes the dependency unless its last result is still fresh. The lock
+// is held while probing so that concurrent requests share the same probe.
+func (c *check) run() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if !c.status.CheckedAt.IsZero() && now.Sub(c.status.CheckedAt) < cacheTTL {
+		return c.status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	err := c.probe(ctx)
+
+	c.status.Name = c.name
+	c.status.Critical = c.critical
+	c.status.Healthy = err == nil
+	c.status.Latency = time.Since(now).String()
+	c.status.CheckedAt = now
+	c.status.Error = ""
+	if err != nil {
+		at := now
+		c.status.Error = err.Error()
+		c.status.LastError = err.Error()
+		c.status.LastErrorAt = &at
+	}
+	return c.status
+}
+
+func probeKubernetes(ctx context.Context) error {
+	if auth.MyClientSet == nil {
+		return fmt.Errorf("kubernetes client is not initialised")
+	}
+	return auth.MyClientSet.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+}
+
+func probeRancher(ctx context.Context) error {
+	return probeURL(ctx, config.Get().RancherURL+"/ping")
+}
+
+func probePaymee(ctx context.Context) error {
+	return probeURL(ctx, config.Get().PaymeeURL)
+}
+
+func probeBilling(ctx context.Context) error {
+	return probeURL(ctx, config.Get().BillingURL)
+}
+
+// probeURL tells whether url answers. Any response below 500 means the
+// service is up, even when the path itself needs authentication.
+func probeURL(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s answered with status %d", url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package health
+
+import "time"
+
+// Status is the last result of the check of a dependency
+type Status struct {
+	Name        string     `json:"name"`
+	Critical    bool       `json:"critical"`
+	Healthy     bool       `json:"healthy"`
+	Latency     string     `json:"latency"`
+	CheckedAt   time.Time  `json:"checkedAt"`
+	Error       string     `json:"error,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// Report is the status of every dependency, the service is ready when all
+// the critical ones are healthy and degraded when one of the others is not
+type Report struct {
+	Ready    bool     `json:"ready"`
+	Degraded bool     `json:"degraded"`
+	Checks   []Status `json:"checks"`
+}
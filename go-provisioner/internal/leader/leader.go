@@ -0,0 +1,85 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// Exportable functions
+
+// Run calls run while this replica holds the Lease name, so that only one
+// replica runs it at a time. The ctx given to run is cancelled when the lease
+// is lost, and run is called again once it is won back. Run blocks until ctx
+// is done and run has returned.
+func Run(ctx context.Context, name string, run func(ctx context.Context)) {
+	identity, err := os.Hostname()
+	if err != nil {
+		logger.Error("leader election: cannot read the hostname", "lease", name, "error", err)
+		return
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: getLeaseNamespace(),
+		},
+		Client:     auth.MyClientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	// the election starts run in its own goroutine and does not wait for it,
+	// running makes sure that a new term never overlaps the previous one
+	running := sync.Mutex{}
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   leaseDuration,
+			RenewDeadline:   renewDeadline,
+			RetryPeriod:     retryPeriod,
+			ReleaseOnCancel: true,
+			Name:            name,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(leaderCtx context.Context) {
+					running.Lock()
+					defer running.Unlock()
+					if leaderCtx.Err() != nil {
+						return
+					}
+					logger.Info("leader election: acquired the lease", "lease", name, "identity", identity)
+					run(leaderCtx)
+				},
+				OnStoppedLeading: func() {
+					logger.Info("leader election: released the lease", "lease", name, "identity", identity)
+				},
+			},
+		})
+		running.Lock()
+		running.Unlock()
+	}
+}
+
+// Local functions
+
+// getLeaseNamespace returns the namespace of the leases, LEASE_NAMESPACE or
+// default
+func getLeaseNamespace() string {
+	namespace := config.Get().LeaseNamespace
+	if namespace == "" {
+		return "default"
+	}
+	return namespace
+}
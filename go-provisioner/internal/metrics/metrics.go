@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/internal/tracing"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+const (
+	UpstreamRancher    = "rancher"
+	UpstreamPaymee     = "paymee"
+	UpstreamBilling    = "billing"
+	UpstreamKubernetes = "kubernetes"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, per route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve the HTTP requests, per route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Time taken by the calls to the upstream services.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream", "method"})
+
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_request_errors_total",
+		Help: "Calls to the upstream services that failed or answered with a 5xx status.",
+	}, []string{"upstream", "method"})
+
+	provisionings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "provisioning_total",
+		Help: "Project provisionings, per plan and result.",
+	}, []string{"plan", "result"})
+
+	activeProjects = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "active_projects",
+		Help: "Active projects managed by the provisioner, per plan, as of the last reconciliation.",
+	}, []string{"plan"})
+)
+
+// Exportable functions
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+}
+
+// ObserveRequest records an HTTP request served by the api
+func ObserveRequest(method string, route string, status int, d time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// ObserveProvisioning records the outcome of a project provisioning
+func ObserveProvisioning(plan string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	provisionings.WithLabelValues(plan, result).Inc()
+}
+
+// SetActiveProjects replaces the number of projects per plan
+func SetActiveProjects(counts map[string]int) {
+	activeProjects.Reset()
+	for plan, n := range counts {
+		activeProjects.WithLabelValues(plan).Set(float64(n))
+	}
+}
+
+// Client returns an http client whose calls are measured and traced as
+// calls to upstream, and carry the request id of their context
+func Client(upstream string) *http.Client {
+	return &http.Client{Transport: Transport(upstream, tracing.Transport(upstream, logger.Transport(http.DefaultTransport)))}
+}
+
+// Transport measures the calls made through next as calls to upstream
+func Transport(upstream string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{upstream: upstream, next: next}
+}
+
+// WrapTransport returns a rest.Config WrapTransport measuring and tracing
+// the calls to the Kubernetes api
+func WrapTransport(next http.RoundTripper) http.RoundTripper {
+	return Transport(UpstreamKubernetes, tracing.Transport(UpstreamKubernetes, logger.Transport(next)))
+}
+
+// Local functions
+
+type transport struct {
+	upstream string
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	upstreamDuration.WithLabelValues(t.upstream, req.Method).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= 500 {
+		upstreamErrors.WithLabelValues(t.upstream, req.Method).Inc()
+	}
+	return resp, err
+}
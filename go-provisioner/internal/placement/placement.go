@@ -0,0 +1,218 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the score of a cluster is a weighted sum of factors between 0 and 1
+const (
+	capacityWeight = 0.5
+	regionWeight   = 0.3
+	projectsWeight = 0.2
+)
+
+// labels set by the provisioner on the namespaces of its projects
+const (
+	planLabel      = "creometry.com/plan"
+	projectIdLabel = "field.cattle.io/projectId"
+)
+
+// Exportable functions
+
+// Place scores the candidate clusters of req and returns the best one.
+// Clusters with room for the whole plan quota are chosen first, among them
+// the score favours the free capacity left after placement, the preferred
+// region and the clusters holding fewer projects.
+func Place(ctx context.Context, req Request) (Decision, error) {
+	var clusters []cluster.Cluster
+	var err error
+	if req.Region != "" {
+		clusters, err = cluster.InRegion(req.Region)
+	} else {
+		clusters, err = cluster.List()
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+
+	preferredRegion := req.Region
+	if preferredRegion == "" {
+		preferredRegion = cluster.DefaultRegion()
+	}
+
+	candidates := []Candidate{}
+	maxProjects := 0
+	for _, c := range clusters {
+		candidate := evaluate(ctx, c, req)
+		candidate.PreferredRegion = c.Region == preferredRegion
+		if candidate.Projects > maxProjects {
+			maxProjects = candidate.Projects
+		}
+		candidates = append(candidates, candidate)
+	}
+	for i := range candidates {
+		if candidates[i].Error == "" {
+			candidates[i].Score = score(candidates[i], req, maxProjects)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		if a.Fits != b.Fits {
+			return a.Fits
+		}
+		return a.Score > b.Score
+	})
+
+	best := candidates[0]
+	if best.Error != "" {
+		return Decision{}, apperror.New(apperror.CodeUpstreamUnavailable, "no cluster can be reached to place the project")
+	}
+	return Decision{
+		Cluster:    best.cluster.ToResp(),
+		Reason:     explain(best, len(candidates)),
+		Candidates: candidates,
+	}, nil
+}
+
+// Local functions
+
+func evaluate(ctx context.Context, c cluster.Cluster, req Request) Candidate {
+	candidate := Candidate{
+		ClusterId: c.Id,
+		Region:    c.Region,
+		cluster:   c,
+	}
+
+	clientSet, err := cluster.ClientSet(c.RancherClusterId)
+	if err == nil {
+		err = measureCapacity(ctx, clientSet, &candidate)
+	}
+	if err == nil {
+		candidate.Projects, err = countProjects(ctx, clientSet)
+	}
+	if err != nil {
+		candidate.Error = err.Error()
+		return candidate
+	}
+
+	candidate.Fits = candidate.FreeCpuMilli >= req.CpuMilli && candidate.FreeMemoryBytes >= req.MemoryBytes
+	return candidate
+}
+
+// measureCapacity sets the allocatable capacity of the ready and schedulable
+// nodes, and what is left of it once the requests of the running pods are
+// subtracted
+func measureCapacity(ctx context.Context, clientSet kubernetes.Interface, candidate *Candidate) error {
+	nodes, err := clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	usable := map[string]bool{}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !isReady(node) {
+			continue
+		}
+		usable[node.Name] = true
+		candidate.allocCpuMilli += node.Status.Allocatable.Cpu().MilliValue()
+		candidate.allocMemoryBytes += node.Status.Allocatable.Memory().Value()
+	}
+
+	pods, err := clientSet.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var requestedCpu, requestedMemory int64
+	for _, pod := range pods.Items {
+		if !usable[pod.Spec.NodeName] || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			requestedCpu += container.Resources.Requests.Cpu().MilliValue()
+			requestedMemory += container.Resources.Requests.Memory().Value()
+		}
+	}
+
+	candidate.FreeCpuMilli = candidate.allocCpuMilli - requestedCpu
+	candidate.FreeMemoryBytes = candidate.allocMemoryBytes - requestedMemory
+	return nil
+}
+
+func isReady(node v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func countProjects(ctx context.Context, clientSet kubernetes.Interface) (int, error) {
+	list, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s,%s", planLabel, projectIdLabel),
+	})
+	if err != nil {
+		return 0, err
+	}
+	projects := map[string]bool{}
+	for _, ns := range list.Items {
+		projects[ns.Labels[projectIdLabel]] = true
+	}
+	return len(projects), nil
+}
+
+func score(c Candidate, req Request, maxProjects int) float64 {
+	capacity := math.Min(
+		ratio(c.FreeCpuMilli-req.CpuMilli, c.allocCpuMilli),
+		ratio(c.FreeMemoryBytes-req.MemoryBytes, c.allocMemoryBytes),
+	)
+
+	region := 0.0
+	if c.PreferredRegion {
+		region = 1
+	}
+
+	projects := 1.0
+	if maxProjects > 0 {
+		projects = 1 - float64(c.Projects)/float64(maxProjects)
+	}
+
+	return capacityWeight*capacity + regionWeight*region + projectsWeight*projects
+}
+
+// ratio returns part/total clamped between 0 and 1
+func ratio(part int64, total int64) float64 {
+	if total <= 0 || part <= 0 {
+		return 0
+	}
+	return math.Min(float64(part)/float64(total), 1)
+}
+
+func explain(c Candidate, candidates int) string {
+	reasons := []string{
+		fmt.Sprintf("%dm cpu and %dMi memory free", c.FreeCpuMilli, c.FreeMemoryBytes/(1024*1024)),
+		fmt.Sprintf("%d projects", c.Projects),
+	}
+	if c.PreferredRegion {
+		reasons = append(reasons, "preferred region")
+	}
+	msg := fmt.Sprintf("cluster %s in region %s has the best score %.2f of %d candidates (%s)",
+		c.ClusterId, c.Region, c.Score, candidates, strings.Join(reasons, ", "))
+	if !c.Fits {
+		msg += ", no cluster has room for the whole plan quota"
+	}
+	return msg
+}
@@ -0,0 +1,35 @@
+package placement
+
+import "github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+
+// Request is what a new project needs from its cluster
+type Request struct {
+	// Region restricts the placement to a region, when it is empty every
+	// cluster is a candidate and DEFAULT_REGION is preferred
+	Region      string
+	CpuMilli    int64
+	MemoryBytes int64
+}
+
+type Decision struct {
+	Cluster    cluster.RespDataCluster `json:"cluster"`
+	Reason     string                  `json:"reason"`
+	Candidates []Candidate             `json:"candidates"`
+}
+
+// Candidate is the evaluation of one cluster
+type Candidate struct {
+	ClusterId       string  `json:"clusterId"`
+	Region          string  `json:"region"`
+	FreeCpuMilli    int64   `json:"freeCpuMilli"`
+	FreeMemoryBytes int64   `json:"freeMemoryBytes"`
+	Projects        int     `json:"projects"`
+	PreferredRegion bool    `json:"preferredRegion"`
+	Fits            bool    `json:"fits"`
+	Score           float64 `json:"score"`
+	Error           string  `json:"error,omitempty"`
+
+	cluster          cluster.Cluster
+	allocCpuMilli    int64
+	allocMemoryBytes int64
+}
@@ -0,0 +1,246 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	baselineNetworkPolicy  = "networkpolicy"
+	baselineLimitRange     = "limitrange"
+	baselinePodSecurity    = "podsecurity"
+	baselineServiceAccount = "serviceaccount"
+
+	defaultPodSecurityLevel = "baseline"
+	defaultIngressNamespace = "ingress-nginx"
+	namespaceNameLabel      = "kubernetes.io/metadata.name"
+	networkPolicyName       = "default-deny-ingress"
+	limitRangeName          = "default-limits"
+)
+
+// containerDefaults are the requests and limits given to containers that do
+// not set their own, so their pods fit in the plan quota
+type containerDefaults struct {
+	RequestsCpu    string
+	RequestsMemory string
+	LimitsCpu      string
+	LimitsMemory   string
+}
+
+var planContainerDefaults = map[string]containerDefaults{
+	PlanTrial: {RequestsCpu: "50m", RequestsMemory: "64Mi", LimitsCpu: "250m", LimitsMemory: "256Mi"},
+	"Starter": {RequestsCpu: "100m", RequestsMemory: "128Mi", LimitsCpu: "500m", LimitsMemory: "512Mi"},
+	"Pro":     {RequestsCpu: "100m", RequestsMemory: "128Mi", LimitsCpu: "1000m", LimitsMemory: "1Gi"},
+	"Elite":   {RequestsCpu: "200m", RequestsMemory: "256Mi", LimitsCpu: "2000m", LimitsMemory: "2Gi"},
+}
+
+// applyNamespaceBaseline applies the baseline bundle configured in
+// BASELINE_POLICIES to a project namespace. Every step creates or updates
+// its object so the function can be run again on an existing namespace.
+func applyNamespaceBaseline(ctx context.Context, nsName string, projectId string, plan string) error {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return err
+	}
+	for _, policy := range getBaselinePolicies() {
+		switch policy {
+		case baselineNetworkPolicy:
+			err = applyNetworkPolicy(ctx, clientSet, nsName, projectId)
+		case baselineLimitRange:
+			err = applyLimitRange(ctx, clientSet, nsName, plan)
+		case baselinePodSecurity:
+			err = applyPodSecurityLabels(ctx, clientSet, nsName)
+		case baselineServiceAccount:
+			err = applyDefaultServiceAccount(ctx, clientSet, nsName)
+		default:
+			err = fmt.Errorf("unknown baseline policy %s", policy)
+		}
+		if err != nil {
+			return fmt.Errorf("applying %s baseline to %s: %w", policy, nsName, err)
+		}
+	}
+	return nil
+}
+
+func getBaselinePolicies() []string {
+	value := config.Get().BaselinePolicies
+	if value == "" {
+		return []string{baselineNetworkPolicy, baselineLimitRange, baselinePodSecurity, baselineServiceAccount}
+	}
+	res := []string{}
+	for _, policy := range strings.Split(value, ",") {
+		if policy = strings.TrimSpace(policy); policy != "" && policy != "none" {
+			res = append(res, policy)
+		}
+	}
+	return res
+}
+
+// applyNetworkPolicy denies the ingress traffic that does not come from a
+// namespace of the same project or from the ingress controller
+func applyNetworkPolicy(ctx context.Context, clientSet kubernetes.Interface, nsName string, projectId string) error {
+	client := clientSet.NetworkingV1().NetworkPolicies(nsName)
+	spec := networkPolicySpec(projectId)
+
+	existing, err := client.Get(ctx, networkPolicyName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: networkPolicyName},
+			Spec:       spec,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Spec = spec
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+func networkPolicySpec(projectId string) networkingv1.NetworkPolicySpec {
+	return networkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{},
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		Ingress: []networkingv1.NetworkPolicyIngressRule{
+			{
+				From: []networkingv1.NetworkPolicyPeer{
+					{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								projectIdLabel: strings.Split(projectId, ":")[1],
+							},
+						},
+					},
+					// the ingresses of the project are served through the
+					// ingress controller, its namespace is in no project
+					{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchExpressions: []metav1.LabelSelectorRequirement{
+								{
+									Key:      namespaceNameLabel,
+									Operator: metav1.LabelSelectorOpIn,
+									Values:   getIngressNamespaces(),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getIngressNamespaces returns the namespaces of the ingress controllers set
+// in INGRESS_NAMESPACES, ingress-nginx when it is not set
+func getIngressNamespaces() []string {
+	res := []string{}
+	for _, namespace := range strings.Split(config.Get().IngressNamespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			res = append(res, namespace)
+		}
+	}
+	if len(res) == 0 {
+		return []string{defaultIngressNamespace}
+	}
+	return res
+}
+
+func applyLimitRange(ctx context.Context, clientSet kubernetes.Interface, nsName string, plan string) error {
+	defaults, ok := planContainerDefaults[plan]
+	if !ok {
+		return fmt.Errorf("no container defaults for plan %s", plan)
+	}
+
+	client := clientSet.CoreV1().LimitRanges(nsName)
+
+	spec := v1.LimitRangeSpec{
+		Limits: []v1.LimitRangeItem{
+			{
+				Type: v1.LimitTypeContainer,
+				Default: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(defaults.LimitsCpu),
+					v1.ResourceMemory: resource.MustParse(defaults.LimitsMemory),
+				},
+				DefaultRequest: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(defaults.RequestsCpu),
+					v1.ResourceMemory: resource.MustParse(defaults.RequestsMemory),
+				},
+			},
+		},
+	}
+
+	existing, err := client.Get(ctx, limitRangeName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, &v1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: limitRangeName},
+			Spec:       spec,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Spec = spec
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// applyPodSecurityLabels enforces the configured Pod Security Admission level
+// and warns about everything that would not pass the restricted level
+func applyPodSecurityLabels(ctx context.Context, clientSet kubernetes.Interface, nsName string) error {
+	level := config.Get().BaselinePodSecurityLevel
+	if level == "" {
+		level = defaultPodSecurityLevel
+	}
+
+	nsClient := clientSet.CoreV1().Namespaces()
+	ns, err := nsClient.Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	ns.Labels["pod-security.kubernetes.io/enforce"] = level
+	ns.Labels["pod-security.kubernetes.io/enforce-version"] = "latest"
+	ns.Labels["pod-security.kubernetes.io/warn"] = "restricted"
+	ns.Labels["pod-security.kubernetes.io/audit"] = "restricted"
+	_, err = nsClient.Update(ctx, ns, metav1.UpdateOptions{})
+	return err
+}
+
+// applyDefaultServiceAccount stops the default ServiceAccount from mounting
+// its token in every pod. The account is created here when the controller
+// manager has not created it yet.
+func applyDefaultServiceAccount(ctx context.Context, clientSet kubernetes.Interface, nsName string) error {
+	client := clientSet.CoreV1().ServiceAccounts(nsName)
+	automount := false
+
+	existing, err := client.Get(ctx, "default", metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, &v1.ServiceAccount{
+			ObjectMeta:                   metav1.ObjectMeta{Name: "default"},
+			AutomountServiceAccountToken: &automount,
+		}, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			return applyDefaultServiceAccount(ctx, clientSet, nsName)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.AutomountServiceAccountToken = &automount
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,263 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultGCMinAge       = 24 * time.Hour
+	defaultGCMaxDeletions = 10
+
+	OrphanKindNamespace = "namespace"
+	OrphanKindProject   = "project"
+)
+
+// Exportable functions
+
+// CollectGarbage looks for the leftovers of failed provisioning runs:
+// namespaces of the provisioner whose Rancher project does not exist anymore
+// and Rancher projects of the provisioner unknown to the billing service.
+// Orphans older than minAge are deleted unless dryRun is set, at most
+// maxDeletions of them per run so that a wrong answer of the billing service
+// cannot wipe out the projects.
+func CollectGarbage(ctx context.Context, req ReqDataGC) (RespDataGC, error) {
+	minAge := config.Get().Duration("GC_MIN_AGE", defaultGCMinAge)
+	if req.MinAge != "" {
+		d, err := time.ParseDuration(req.MinAge)
+		if err != nil {
+			return RespDataGC{}, err
+		}
+		minAge = d
+	}
+	maxDeletions := config.Get().Int("GC_MAX_DELETIONS", defaultGCMaxDeletions)
+	if req.MaxDeletions > 0 {
+		maxDeletions = req.MaxDeletions
+	}
+
+	projects, err := listAllRancherProjects(ctx)
+	if err != nil {
+		return RespDataGC{}, err
+	}
+
+	now := time.Now()
+	orphans, err := findOrphanNamespaces(ctx, projects)
+	if err != nil {
+		return RespDataGC{}, err
+	}
+	orphanProjects, err := findOrphanProjects(ctx, projects)
+	if err != nil {
+		return RespDataGC{}, err
+	}
+	orphans = append(orphans, orphanProjects...)
+
+	deletions := 0
+	for i := range orphans {
+		o := &orphans[i]
+		createdAt, err := time.Parse(time.RFC3339, o.CreatedAt)
+		o.Expired = err == nil && now.Sub(createdAt) >= minAge
+		if req.DryRun || !o.Expired {
+			continue
+		}
+		if deletions >= maxDeletions {
+			o.Error = "max deletions per run reached"
+			continue
+		}
+		deletions++
+		err = deleteOrphan(ctx, *o)
+		if err != nil {
+			o.Error = err.Error()
+			logger.FromContext(ctx).Error("gc: cannot delete an orphan", "kind", o.Kind, "name", o.Name, "error", err)
+			continue
+		}
+		o.Deleted = true
+		logger.FromContext(ctx).Info("gc: deleted an orphan", "kind", o.Kind, "name", o.Name, "reason", o.Reason)
+	}
+
+	return RespDataGC{
+		DryRun:       req.DryRun,
+		MinAge:       minAge.String(),
+		MaxDeletions: maxDeletions,
+		Orphans:      orphans,
+	}, nil
+}
+
+// Local functions
+
+// findOrphanNamespaces returns the namespaces created by the provisioner whose
+// project is not one of projects
+func findOrphanNamespaces(ctx context.Context, projects []RancherProject) ([]Orphan, error) {
+	known := map[string]bool{}
+	for _, pr := range projects {
+		known[pr.Id] = true
+	}
+
+	clusters, err := cluster.List()
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []Orphan{}
+	for _, c := range clusters {
+		clientSet, err := cluster.ClientSet(c.RancherClusterId)
+		if err != nil {
+			return nil, err
+		}
+		// only the namespaces holding a plan were created by the provisioner
+		list, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+			LabelSelector: planLabel,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ns := range list.Items {
+			projectId := namespaceProjectId(ns, c.RancherClusterId)
+			if projectId != "" && known[projectId] {
+				continue
+			}
+			orphans = append(orphans, Orphan{
+				Kind:      OrphanKindNamespace,
+				Name:      ns.Name,
+				ClusterId: c.RancherClusterId,
+				ProjectId: projectId,
+				Reason:    "rancher project not found",
+				CreatedAt: ns.CreationTimestamp.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	return orphans, nil
+}
+
+// findOrphanProjects returns the projects created by the provisioner that
+// have no billing record. They are only looked for when the billing lookup is
+// configured.
+func findOrphanProjects(ctx context.Context, projects []RancherProject) ([]Orphan, error) {
+	orphans := []Orphan{}
+	if config.Get().BillingProjectPath == "" {
+		logger.FromContext(ctx).Warn("gc: BILLING_PROJECT_PATH is not set, skipping the orphan projects")
+		return orphans, nil
+	}
+	for _, pr := range projects {
+		if pr.Annotations[managedByAnnotation] != managedBy {
+			continue
+		}
+		found, err := hasBillingRecord(ctx, pr.Id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Kind:      OrphanKindProject,
+			Name:      pr.Name,
+			ClusterId: pr.ClusterId,
+			ProjectId: pr.Id,
+			Reason:    "no billing record",
+			// Rancher timestamps are in milliseconds
+			CreatedAt: time.UnixMilli(pr.CreatedTS).UTC().Format(time.RFC3339),
+		})
+	}
+	return orphans, nil
+}
+
+// namespaceProjectId returns the full project id of a namespace, namespaces
+// missing the annotation are resolved from the label and their cluster
+func namespaceProjectId(ns v1.Namespace, clusterId string) string {
+	if projectId := ns.Annotations[projectIdLabel]; strings.Contains(projectId, ":") {
+		return projectId
+	}
+	prId := ns.Labels[projectIdLabel]
+	if prId == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", clusterId, prId)
+}
+
+// hasBillingRecord asks the billing service for projectId. A project is only
+// reported missing when the service answers 404 with the not_found error code
+// in its body, the 404 of an unknown route or of a proxy must never get a
+// project deleted.
+func hasBillingRecord(ctx context.Context, projectId string) (bool, error) {
+	projectURL, err := billingProjectURL(projectId)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", projectURL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := metrics.Client(metrics.UpstreamBilling).Do(req)
+	if err != nil {
+		return false, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		body := RespDataBillingError{}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+		if err == nil && body.Code == string(apperror.CodeNotFound) {
+			return false, nil
+		}
+		return false, apperror.Newf(apperror.CodeUpstreamUnavailable, "billing lookup of project %s answered 404 without a not_found code", projectId)
+	default:
+		// never treat a failing billing service as a missing record
+		return false, apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("billing lookup of project %s failed with status %d", projectId, resp.StatusCode))
+	}
+}
+
+// billingProjectURL returns the url of projectId in the billing service,
+// BILLING_PROJECT_PATH must be set
+func billingProjectURL(projectId string) (string, error) {
+	parts := strings.Split(projectId, ":")
+	if len(parts) != 2 {
+		return "", apperror.Newf(apperror.CodeValidationFailed, "invalid projectId %s", projectId)
+	}
+	path := config.Get().BillingProjectPath
+	if path == "" {
+		return "", apperror.New(apperror.CodeInternal, "BILLING_PROJECT_PATH is not set")
+	}
+	path = strings.NewReplacer("{clusterId}", url.PathEscape(parts[0]), "{projectId}", url.PathEscape(parts[1])).Replace(path)
+	return config.Get().BillingURL + path, nil
+}
+
+func deleteOrphan(ctx context.Context, o Orphan) error {
+	clientSet, err := cluster.ClientSet(o.ClusterId)
+	if err != nil {
+		return err
+	}
+	if o.Kind == OrphanKindNamespace {
+		return clientSet.CoreV1().Namespaces().Delete(ctx, o.Name, metav1.DeleteOptions{})
+	}
+
+	// the namespaces of an orphan project are orphans as well
+	namespaces, err := listProjectNamespaces(ctx, o.ProjectId)
+	if err == nil {
+		for _, ns := range namespaces {
+			err = clientSet.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return deleteRancherProject(ctx, o.ProjectId)
+}
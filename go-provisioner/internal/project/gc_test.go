@@ -0,0 +1,138 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newProvisionedNamespace(name string, prId string, createdAt time.Time) *v1.Namespace {
+	labels := map[string]string{planLabel: PlanTrial}
+	if prId != "" {
+		labels[projectIdLabel] = prId
+	}
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(createdAt),
+		},
+	}
+}
+
+func TestCollectGarbageSelectsOrphans(t *testing.T) {
+	logger.SetOutput(io.Discard)
+	old := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name         string
+		dryRun       bool
+		maxDeletions int
+		wantDeleted  int
+	}{
+		{name: "dry run", dryRun: true, maxDeletions: 10},
+		{name: "all expired orphans", maxDeletions: 10, wantDeleted: 3},
+		{name: "max deletions", maxDeletions: 1, wantDeleted: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/v3/projects":
+					fmt.Fprintf(w, `{"data":[
+						{"id":"c-abcde:p-known","name":"known","clusterId":"c-abcde","annotations":{%[1]q:%[2]q}},
+						{"id":"c-abcde:p-unbilled","name":"unbilled","clusterId":"c-abcde","createdTS":%[3]d,"annotations":{%[1]q:%[2]q}},
+						{"id":"c-abcde:p-foreign","name":"foreign","clusterId":"c-abcde","annotations":{}}
+					]}`, managedByAnnotation, managedBy, old.UnixMilli())
+				case r.Method == "GET" && r.URL.Path == "/billing/c-abcde/p-known":
+					w.Write([]byte(`{}`))
+				case r.Method == "GET" && r.URL.Path == "/billing/c-abcde/p-unbilled":
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"error":"project not found","code":"not_found"}`))
+				case r.Method == "DELETE" && r.URL.Path == "/v3/projects/c-abcde:p-unbilled":
+					if tt.dryRun {
+						t.Errorf("dry run deleted the Rancher project %s", r.URL.Path)
+					}
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+			loadTestConfig(t, srv.URL)
+			t.Setenv("BILLING_URL", srv.URL)
+			t.Setenv("BILLING_PROJECT_PATH", "/billing/{clusterId}/{projectId}")
+			if err := config.Load(); err != nil {
+				t.Fatalf("cannot load the test configuration: %v", err)
+			}
+
+			clientSet := fake.NewSimpleClientset(
+				newProvisionedNamespace("p-known-default", "p-known", old),
+				newProvisionedNamespace("p-gone-default", "p-gone", old),
+				newProvisionedNamespace("p-recent-default", "p-recent", time.Now()),
+				newProvisionedNamespace("unlabelled", "", old),
+				&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", CreationTimestamp: metav1.NewTime(old)}},
+			)
+			cluster.SetClientSet("c-abcde", clientSet)
+
+			res, err := CollectGarbage(context.Background(), ReqDataGC{DryRun: tt.dryRun, MinAge: "24h", MaxDeletions: tt.maxDeletions})
+			if err != nil {
+				t.Fatalf("CollectGarbage() failed: %v", err)
+			}
+
+			wantExpired := map[string]bool{
+				"p-gone-default":   true,
+				"p-recent-default": false,
+				"unlabelled":       true,
+				"unbilled":         true,
+			}
+			deleted := 0
+			for _, o := range res.Orphans {
+				expired, ok := wantExpired[o.Name]
+				if !ok {
+					t.Errorf("%s %s is not an orphan", o.Kind, o.Name)
+					continue
+				}
+				delete(wantExpired, o.Name)
+				if o.Expired != expired {
+					t.Errorf("%s expired is %v, want %v", o.Name, o.Expired, expired)
+				}
+				if o.Deleted {
+					deleted++
+				} else if o.Expired && !tt.dryRun && o.Error == "" {
+					t.Errorf("expired orphan %s was neither deleted nor reported", o.Name)
+				}
+			}
+			for name := range wantExpired {
+				t.Errorf("orphan %s was not found", name)
+			}
+			if deleted != tt.wantDeleted {
+				t.Fatalf("%d orphans deleted, want %d", deleted, tt.wantDeleted)
+			}
+
+			kept := []string{"p-known-default", "p-recent-default", "kube-system"}
+			if tt.dryRun {
+				kept = append(kept, "p-gone-default", "unlabelled")
+			}
+			for _, name := range kept {
+				_, err := clientSet.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Errorf("namespace %s was not kept: %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package project
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	defaultKubeconfigTTL    = 8 * time.Hour
+	defaultKubeconfigMaxTTL = 24 * time.Hour
+	// the api server refuses tokens valid for less than 10 minutes
+	minKubeconfigTTL = 10 * time.Minute
+)
+
+// projectRoleClusterRoles maps the Rancher project roles to the Kubernetes
+// ClusterRole granted in every namespace of the project, from the most to
+// the least privileged
+var projectRoleClusterRoles = []struct {
+	RoleTemplateId string
+	ClusterRole    string
+}{
+	{RoleTemplateId: "project-owner", ClusterRole: "admin"},
+	{RoleTemplateId: "project-member", ClusterRole: "edit"},
+	{RoleTemplateId: "read-only", ClusterRole: "view"},
+}
+
+// Exportable functions
+
+// GetProjectKubeConfig issues a kubeconfig limited to the namespaces of the
+// project. It is backed by a ServiceAccount dedicated to the user, bound in
+// each namespace to the ClusterRole matching the user's project role, and a
+// token that expires after the requested lifetime.
+func GetProjectKubeConfig(ctx context.Context, projectId string, req ReqDataProjectKubeconfig) (RespDataProjectKubeconfig, error) {
+	user, err := getUserFromToken(ctx, req.Token)
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+
+	clusterRole, err := getUserClusterRole(ctx, projectId, user.Id)
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+
+	// the ServiceAccount lives in the first namespace and is bound in all of them
+	saNamespace := namespaces[0].Name
+	saName := fmt.Sprintf("kubeconfig-%s", strings.ToLower(user.Id))
+
+	err = ensureKubeconfigServiceAccount(ctx, clientSet, saNamespace, saName, user.Id)
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+	for _, ns := range namespaces {
+		err = ensureKubeconfigRoleBinding(ctx, clientSet, ns.Name, saNamespace, saName, clusterRole)
+		if err != nil {
+			return RespDataProjectKubeconfig{}, err
+		}
+	}
+
+	ttl := getKubeconfigTTL(req.ExpirationSeconds)
+	expirationSeconds := int64(ttl.Seconds())
+	tokenRequest, err := clientSet.CoreV1().ServiceAccounts(saNamespace).CreateToken(ctx, saName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+
+	config, err := buildKubeconfig(projectId, saNamespace, saName, tokenRequest.Status.Token)
+	if err != nil {
+		return RespDataProjectKubeconfig{}, err
+	}
+
+	return RespDataProjectKubeconfig{
+		Config:    config,
+		Namespace: saNamespace,
+		Role:      clusterRole,
+		ExpiresAt: tokenRequest.Status.ExpirationTimestamp.Time,
+	}, nil
+}
+
+// Local functions
+
+func getUserClusterRole(ctx context.Context, projectId string, userId string) (string, error) {
+	bindings, err := listProjectRoleBindings(ctx, projectId, userId)
+	if err != nil {
+		return "", err
+	}
+	for _, role := range projectRoleClusterRoles {
+		for _, binding := range bindings {
+			if binding.RoleTemplateId == role.RoleTemplateId {
+				return role.ClusterRole, nil
+			}
+		}
+	}
+	return "", apperror.New(apperror.CodeForbidden, "user is not a member of the project")
+}
+
+func ensureKubeconfigServiceAccount(ctx context.Context, clientSet kubernetes.Interface, namespace string, name string, userId string) error {
+	_, err := clientSet.CoreV1().ServiceAccounts(namespace).Create(ctx, &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				ownerAnnotation: userId,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func ensureKubeconfigRoleBinding(ctx context.Context, clientSet kubernetes.Interface, namespace string, saNamespace string, saName string, clusterRole string) error {
+	client := clientSet.RbacV1().RoleBindings(namespace)
+
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: saName,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      saName,
+				Namespace: saNamespace,
+			},
+		},
+	}
+
+	existing, err := client.Get(ctx, saName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, rb, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if existing.RoleRef == rb.RoleRef {
+		return nil
+	}
+
+	// the role of a binding cannot be changed, it has to be recreated
+	err = client.Delete(ctx, saName, metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = client.Create(ctx, rb, metav1.CreateOptions{})
+	return err
+}
+
+func getKubeconfigTTL(requestedSeconds int64) time.Duration {
+	maxTTL := config.Get().Duration("KUBECONFIG_TOKEN_MAX_TTL", defaultKubeconfigMaxTTL)
+	ttl := config.Get().Duration("KUBECONFIG_TOKEN_TTL", defaultKubeconfigTTL)
+	if requestedSeconds > 0 {
+		ttl = time.Duration(requestedSeconds) * time.Second
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	if ttl < minKubeconfigTTL {
+		ttl = minKubeconfigTTL
+	}
+	return ttl
+}
+
+func buildKubeconfig(projectId string, namespace string, saName string, token string) (string, error) {
+	c, err := cluster.ForProject(projectId)
+	if err != nil {
+		return "", err
+	}
+
+	server := c.ApiServer
+	if server == "" {
+		server = config.Get().KubeAPIServer
+		if server == "" {
+			return "", fmt.Errorf("no api server configured for cluster %s", c.Id)
+		}
+	}
+
+	caData, err := getKubeAPICA(c)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.Replace(projectId, ":", "-", 1)
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = &clientcmdapi.Cluster{
+		Server:                   server,
+		CertificateAuthorityData: caData,
+	}
+	config.AuthInfos[saName] = &clientcmdapi.AuthInfo{
+		Token: token,
+	}
+	config.Contexts[name] = &clientcmdapi.Context{
+		Cluster:   name,
+		AuthInfo:  saName,
+		Namespace: namespace,
+	}
+	config.CurrentContext = name
+
+	b, err := clientcmd.Write(*config)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// getKubeAPICA returns the CA of the api server of the cluster, or
+// KUBE_API_CA_DATA, both base64 encoded since config values are read as a
+// single line, or the CA the provisioner itself uses to reach the cluster
+func getKubeAPICA(c cluster.Cluster) ([]byte, error) {
+	if c.ApiCAData != "" {
+		return base64.StdEncoding.DecodeString(c.ApiCAData)
+	}
+	if ca := config.Get().KubeAPICAData; ca != "" {
+		return base64.StdEncoding.DecodeString(ca)
+	}
+	config, err := cluster.Config(c.RancherClusterId)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.TLSClientConfig.CAData) > 0 {
+		return config.TLSClientConfig.CAData, nil
+	}
+	if config.TLSClientConfig.CAFile != "" {
+		return ioutil.ReadFile(config.TLSClientConfig.CAFile)
+	}
+	return nil, nil
+}
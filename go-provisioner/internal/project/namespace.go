@@ -0,0 +1,125 @@
+package project
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// planMaxNamespaces is the number of namespaces a project can hold on each plan
+var planMaxNamespaces = map[string]int{
+	PlanTrial: 1,
+	"Starter": 2,
+	"Pro":     5,
+	"Elite":   10,
+}
+
+// Exportable functions
+
+func ListNamespaces(ctx context.Context, projectId string) ([]string, error) {
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+	return namespaceNames(namespaces), nil
+}
+
+// AddNamespace creates a namespace with a user chosen name in the project.
+// The new namespace inherits the plan, owner and state of the project.
+func AddNamespace(ctx context.Context, projectId string, nsName string) (string, error) {
+	if errs := validation.IsDNS1123Label(nsName); len(errs) > 0 {
+		return "", apperror.Newf(apperror.CodeValidationFailed, "invalid namespace name %s: %s", nsName, strings.Join(errs, ", "))
+	}
+
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return "", err
+	}
+
+	plan, err := getProjectPlan(ctx, projectId, namespaces[0].Labels[planLabel])
+	if err != nil {
+		return "", err
+	}
+	max, ok := planMaxNamespaces[plan]
+	if !ok {
+		return "", apperror.Newf(apperror.CodeValidationFailed, "unknown plan %s", plan)
+	}
+	if len(namespaces) >= max {
+		return "", apperror.Newf(apperror.CodeValidationFailed, "the %s plan is limited to %d namespaces", plan, max)
+	}
+
+	// copy the project annotations of an existing namespace
+	annotations := map[string]string{}
+	for k, v := range namespaces[0].Annotations {
+		if strings.HasPrefix(k, "creometry.com/") {
+			annotations[k] = v
+		}
+	}
+
+	name, err := createProjectNamespace(ctx, nsName, projectId, plan, annotations)
+	if err != nil {
+		return "", err
+	}
+
+	// a namespace added to a suspended project must be suspended as well
+	if annotations[stateAnnotation] == ProjectStateSuspended {
+		clientSet, err := clientFor(projectId)
+		if err != nil {
+			return "", err
+		}
+		err = createSuspendedQuota(ctx, clientSet, name)
+		if err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// DeleteNamespace deletes a namespace of the project, the last namespace of a
+// project cannot be deleted
+func DeleteNamespace(ctx context.Context, projectId string, nsName string) error {
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, ns := range namespaces {
+		if ns.Name == nsName {
+			found = true
+		}
+	}
+	if !found {
+		return apperror.Newf(apperror.CodeNotFound, "namespace %s does not belong to project %s", nsName, projectId)
+	}
+	if len(namespaces) == 1 {
+		return apperror.New(apperror.CodeValidationFailed, "a project must keep at least one namespace")
+	}
+
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return err
+	}
+	return clientSet.CoreV1().Namespaces().Delete(ctx, nsName, metav1.DeleteOptions{})
+}
+
+// Local functions
+
+// getProjectPlan reads the plan from the Rancher project annotations, projects
+// created before the annotation existed fall back to the namespace label
+func getProjectPlan(ctx context.Context, projectId string, fallback string) (string, error) {
+	pr, err := getRancherProject(ctx, projectId)
+	if err != nil {
+		return "", err
+	}
+	if plan := pr.Annotations[planAnnotation]; plan != "" {
+		return plan, nil
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", apperror.Newf(apperror.CodeNotFound, "no plan found for project %s", projectId)
+}
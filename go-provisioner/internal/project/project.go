@@ -4,37 +4,107 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Creometry/dashboard/go-provisioner/auth"
-	"github.com/Creometry/dashboard/go-provisioner/utils"
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/catalog"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"github.com/Creometry/dashboard/go-provisioner/internal/placement"
+	"github.com/Creometry/dashboard/go-provisioner/internal/tracing"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// the running provisionings, a shutdown waits for them so that a project is
+// not left between its Rancher project and its namespace
+var provisionings sync.WaitGroup
+
 // Exportable functions
 
-func ProvisionProject(req ReqData) (data RespDataProvisionProject, err error) {
-	// check paymee payment
-	_, err = checkPayment(req.PaymentToken)
+// ProvisionProject creates the Rancher project, the billing entry and the
+// namespace of a new project. Every step is traced as a child span of ctx.
+func ProvisionProject(ctx context.Context, req ReqData) (data RespDataProvisionProject, err error) {
+	provisionings.Add(1)
+	defer provisionings.Done()
+
+	defer func() {
+		// the plan comes from the request, keep the label set bounded
+		plan := req.Plan
+		if genResourceQuotaFromPlan(plan) == "nil" {
+			plan = "unknown"
+		}
+		metrics.ObserveProvisioning(plan, err)
+	}()
+
+	if req.Plan == PlanTrial {
+		// trials skip the payment but are limited to one per user, the trial
+		// is reserved before the project is created so that two concurrent
+		// provisionings cannot both get one
+		_, span := tracing.Start(ctx, "project.reserveTrial")
+		err = checkTrialEligibility(ctx, req.UserId)
+		if err == nil {
+			err = reserveTrial(ctx, req.UserId)
+		}
+		tracing.End(span, err)
+		if err == nil {
+			defer func() {
+				if err == nil {
+					return
+				}
+				releaseErr := releaseTrial(ctx, req.UserId)
+				if releaseErr != nil {
+					logger.FromContext(ctx).Error("cannot release the trial of a failed provisioning", "userId", req.UserId, "error", releaseErr)
+				}
+			}()
+		}
+	} else {
+		// check paymee payment
+		_, err = checkPayment(ctx, req.PaymentToken)
+	}
+	if err != nil {
+		return RespDataProvisionProject{}, err
+	}
+
+	annotations := map[string]string{
+		planAnnotation:      req.Plan,
+		ownerAnnotation:     req.UserId,
+		managedByAnnotation: managedBy,
+	}
+	if req.Plan == PlanTrial {
+		annotations[trialExpiresAtAnnotation] = trialExpiry(time.Now()).Format(time.RFC3339)
+	}
+
+	_, span := tracing.Start(ctx, "project.place")
+	decision, err := PlaceProject(ctx, req.Plan, req.Region)
+	tracing.End(span, err)
+	if err != nil {
+		return RespDataProvisionProject{}, err
+	}
+	c, err := cluster.Get(decision.Cluster.RancherClusterId)
 	if err != nil {
 		return RespDataProvisionProject{}, err
 	}
+	logger.FromContext(ctx).Info("placing a project", "project", req.UsrProjectName, "cluster", c.RancherClusterId, "reason", decision.Reason)
 
 	// create rancher project
-	projectId, createdTS, p_uuid, err := createRancherProject(req.UsrProjectName, req.Plan)
+	projectId, createdTS, p_uuid, err := createRancherProject(ctx, req.UsrProjectName, c.RancherClusterId, req.Plan, annotations)
 	if err != nil {
 		return RespDataProvisionProject{}, err
 	}
-	log.Println(p_uuid)
+	logger.FromContext(ctx).Info("created the rancher project", "projectId", projectId, "uuid", p_uuid)
 
 	// convert createdTS to time.Time
 	t := time.Unix(0, createdTS)
@@ -42,84 +112,151 @@ func ProvisionProject(req ReqData) (data RespDataProvisionProject, err error) {
 	//create billing account
 	if req.BillingAccountId == "1" {
 		// create billing account
-		accountId, err := createBillingAccount(req, projectId, t)
+		accountId, err := createBillingAccount(ctx, req, projectId, t)
 		if err != nil {
 			return RespDataProvisionProject{}, err
 		}
-		log.Println(accountId)
+		logger.FromContext(ctx).Info("created the billing account", "projectId", projectId, "billingAccountId", accountId)
 	} else {
 		// convert string to uuid
-		uid := uuid.MustParse(req.BillingAccountId)
+		uid, err := uuid.Parse(req.BillingAccountId)
+		if err != nil {
+			return RespDataProvisionProject{}, apperror.Newf(apperror.CodeValidationFailed, "invalid billing account id %s: %v", req.BillingAccountId, err)
+		}
 		// add project to billing account
-		prId, err := addProjectToBillingAccount(uid, projectId, t, req.Plan)
+		prId, err := addProjectToBillingAccount(ctx, uid, projectId, t, req.Plan)
 		if err != nil {
 			return RespDataProvisionProject{}, err
 		}
-		log.Println(prId)
+		logger.FromContext(ctx).Info("added the project to the billing account", "projectId", prId, "billingAccountId", req.BillingAccountId)
 	}
 
 	// add user to project
-	_, err = AddUserToProject(req.UserId, projectId)
+	_, err = AddUserToProject(ctx, req.UserId, projectId)
 	if err != nil {
 		return RespDataProvisionProject{}, err
 	}
 
 	// create k8s namespace
-	nsName, err := createNamespace(req.UsrProjectName, projectId)
-
-	fmt.Printf("Created namespace : %s", nsName)
-
+	nsName, err := createNamespace(ctx, req.UsrProjectName, projectId, req.Plan, annotations)
 	if err != nil {
 		return RespDataProvisionProject{}, err
 	}
+	logger.FromContext(ctx).Info("created the namespace", "projectId", projectId, "namespace", nsName)
 
 	// create gitRepo
 	if req.GitRepoUrl != "" && req.GitRepoBranch != "" && req.GitRepoName != "" {
-		repoName, err := createGitRepo(req.GitRepoName, req.GitRepoUrl, req.GitRepoBranch)
+		repoName, err := createGitRepo(ctx, c.RancherClusterId, req.GitRepoName, req.GitRepoUrl, req.GitRepoBranch)
 		if err != nil {
 			return RespDataProvisionProject{}, err
 		}
-		fmt.Printf("Created repo : %s", repoName)
+		logger.FromContext(ctx).Info("created the git repo", "projectId", projectId, "repo", repoName)
 	}
 
 	resp := RespDataProvisionProject{
 		ProjectId: projectId,
+		Namespace: nsName,
+		Placement: &decision,
+	}
+
+	// apply the starter applications, failed objects are reported without
+	// failing the provisioning
+	if req.Template != "" {
+		_, span := tracing.Start(ctx, "project.applyTemplate", trace.WithAttributes(attribute.String("template", req.Template)))
+		results, err := catalog.Apply(ctx, req.Template, req.TemplateParams, nsName, c.RancherClusterId)
+		tracing.End(span, err)
+		if err != nil {
+			return RespDataProvisionProject{}, err
+		}
+		resp.Template = results
+	}
+
+	// the reconciler only repairs the projects marked as fully provisioned
+	_, span = tracing.Start(ctx, "project.markProvisioned")
+	markErr := markProvisioned(ctx, projectId, time.Now())
+	tracing.End(span, markErr)
+	if markErr != nil {
+		logger.FromContext(ctx).Warn("cannot mark the project as provisioned, it will not be reconciled", "projectId", projectId, "error", markErr)
+	}
+
+	if req.Plan == PlanTrial {
+		// the reservation already holds the trial, the marker on the Rancher
+		// user only records the project that used it
+		_, span := tracing.Start(ctx, "project.markTrialUsed")
+		markErr = markTrialUsed(ctx, req.UserId, projectId)
+		tracing.End(span, markErr)
+		if markErr != nil {
+			logger.FromContext(ctx).Warn("cannot mark the trial as used", "userId", req.UserId, "projectId", projectId, "error", markErr)
+		}
 	}
 	return resp, nil
 
 }
 
-func GetNamespaceByAnnotation(annotations []string) (string, string, error) {
+// WaitForProvisionings blocks until the running provisionings are finished
+// or ctx is done
+func WaitForProvisionings(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		provisionings.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	clusterId, err := utils.GetVariable("config", "CLUSTER_ID")
+// PlaceProject chooses the cluster of a new project of plan, region is
+// optional and restricts the choice to its clusters
+func PlaceProject(ctx context.Context, plan string, region string) (placement.Decision, error) {
+	planQuota, err := GetPlanQuota(plan)
 	if err != nil {
-		return "", "", err
+		return placement.Decision{}, err
 	}
-
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
+	cpu, err := resource.ParseQuantity(planQuota.ResourceQuota.Limit["limitsCpu"])
 	if err != nil {
-		return "", "", err
+		return placement.Decision{}, err
 	}
+	memory, err := resource.ParseQuantity(planQuota.ResourceQuota.Limit["limitsMemory"])
+	if err != nil {
+		return placement.Decision{}, err
+	}
+	return placement.Place(ctx, placement.Request{
+		Region:      region,
+		CpuMilli:    cpu.MilliValue(),
+		MemoryBytes: memory.Value(),
+	})
+}
+
+func GetNamespaceByAnnotation(ctx context.Context, annotations []string) (string, string, error) {
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
+	c, err := cluster.Default()
 	if err != nil {
 		return "", "", err
 	}
+	clusterId := c.RancherClusterId
+
+	rancherURL := config.Get().RancherURL
+
+	rancherToken := config.Get().RancherToken
 
 	// http get request to get the namespace list with http client
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s%s%s", rancherURL, "/k8s/clusters/", clusterId, "/v1/namespaces/"), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s%s%s%s", rancherURL, "/k8s/clusters/", clusterId, "/v1/namespaces/"), nil)
 	if err != nil {
 		return "", "", err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return "", "", err
+		return "", "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -146,30 +283,30 @@ func GetNamespaceByAnnotation(annotations []string) (string, string, error) {
 
 }
 
-func GetKubeConfig(token string) (string, error) {
+func GetKubeConfig(ctx context.Context, token string, clusterId string) (string, error) {
 
-	clusterId, err := utils.GetVariable("config", "CLUSTER_ID")
-	if err != nil {
-		return "", err
+	if clusterId == "" {
+		c, err := cluster.Default()
+		if err != nil {
+			return "", err
+		}
+		clusterId = c.RancherClusterId
 	}
 
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return "", err
-	}
+	rancherURL := config.Get().RancherURL
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v3/clusters/%s?action=generateKubeconfig", rancherURL, clusterId), nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v3/clusters/%s?action=generateKubeconfig", rancherURL, clusterId), nil)
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
@@ -177,50 +314,52 @@ func GetKubeConfig(token string) (string, error) {
 	dt := Kubeconfig{}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	if resp.StatusCode >= 300 {
+		return "", rancherError("POST", "/v3/clusters/"+clusterId+"?action=generateKubeconfig", resp.StatusCode, body)
 	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
-		log.Fatal(err)
+		return "", apperror.Newf(apperror.CodeUpstreamUnavailable, "invalid kubeconfig response from rancher: %v", err)
+	}
+	if dt.Config == "" {
+		return "", apperror.New(apperror.CodeUpstreamUnavailable, "rancher returned an empty kubeconfig")
 	}
 
 	return dt.Config, nil
 }
 
-func AddUserToProject(userId string, projectId string) (RespDataRoleBinding, error) {
+func AddUserToProject(ctx context.Context, userId string, projectId string) (RespDataRoleBinding, error) {
 
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return RespDataRoleBinding{}, err
-	}
+	rancherURL := config.Get().RancherURL
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return RespDataRoleBinding{}, err
-	}
+	rancherToken := config.Get().RancherToken
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", rancherURL, "/v3/projectroletemplatebindings"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"userId":"%s","projectId":"%s","roleTemplateId":"project-member"}`, userId, projectId))))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", rancherURL, "/v3/projectroletemplatebindings"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"userId":"%s","projectId":"%s","roleTemplateId":"project-member"}`, userId, projectId))))
 	if err != nil {
 		return RespDataRoleBinding{}, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return RespDataRoleBinding{}, err
+		return RespDataRoleBinding{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
 	// parse response body
 	dt := RespDataRoleBinding{}
 	body, err := ioutil.ReadAll(resp.Body)
-	log.Print(string(body))
 	if err != nil {
 		return RespDataRoleBinding{}, err
 	}
+	if resp.StatusCode >= 300 {
+		return RespDataRoleBinding{}, rancherError("POST", "/v3/projectroletemplatebindings", resp.StatusCode, body)
+	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
 		return RespDataRoleBinding{}, err
@@ -229,30 +368,24 @@ func AddUserToProject(userId string, projectId string) (RespDataRoleBinding, err
 
 }
 
-func GetUserByUsername(username string) (string, []string, error) {
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return "", []string{}, err
-	}
+func GetUserByUsername(ctx context.Context, username string) (string, []string, error) {
+	rancherURL := config.Get().RancherURL
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return "", []string{}, err
-	}
+	rancherToken := config.Get().RancherToken
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/users?username=", username), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/users?username=", username), nil)
 	if err != nil {
 		return "", []string{}, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return "", []string{}, err
+		return "", []string{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
@@ -262,38 +395,47 @@ func GetUserByUsername(username string) (string, []string, error) {
 	if err != nil {
 		return "", []string{}, err
 	}
+	if resp.StatusCode >= 300 {
+		return "", []string{}, rancherError("GET", "/v3/users", resp.StatusCode, body)
+	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
 		return "", []string{}, err
 	}
 
 	if len(dt.Data) == 0 {
-		return "", []string{}, errors.New("user not found")
+		return "", []string{}, apperror.New(apperror.CodeNotFound, "user not found")
 	}
 	return dt.Data[0].Id, dt.Data[0].PrincipalIds, nil
 
 }
 
-func Login(username string, password string) (string, string, string, error) {
-
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
+// GetCurrentUser returns the id of the Rancher user owning token
+func GetCurrentUser(ctx context.Context, token string) (string, error) {
+	user, err := getUserFromToken(ctx, token)
 	if err != nil {
-		return "", "", "", err
+		return "", err
 	}
+	return user.Id, nil
+}
+
+func Login(ctx context.Context, username string, password string) (string, string, string, error) {
+
+	rancherURL := config.Get().RancherURL
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", rancherURL, "/v3-public/localProviders/local?action=login"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"username":"%s","password":"%s"}`, username, password))))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", rancherURL, "/v3-public/localProviders/local?action=login"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"username":"%s","password":"%s"}`, username, password))))
 	if err != nil {
 		return "", "", "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return "", "", "", err
+		return "", "", "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -303,6 +445,12 @@ func Login(username string, password string) (string, string, string, error) {
 	if err != nil {
 		return "", "", "", err
 	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", "", "", apperror.New(apperror.CodeUnauthorized, "invalid username or password")
+	}
+	if resp.StatusCode >= 300 {
+		return "", "", "", rancherError("POST", "/v3-public/localProviders/local?action=login", resp.StatusCode, body)
+	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
 		return "", "", "", err
@@ -312,33 +460,27 @@ func Login(username string, password string) (string, string, string, error) {
 
 }
 
-func Register(username string) (string, string, string, string, error) {
+func Register(ctx context.Context, username string) (string, string, string, string, error) {
 
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return "", "", "", "", err
-	}
+	rancherURL := config.Get().RancherURL
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return "", "", "", "", err
-	}
+	rancherToken := config.Get().RancherToken
 
 	password := generateRandomString(16)
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", rancherURL, "/v3/users"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"username":"%s","mustChangePassword": true,"password": "%s","enabled": true,"type":"user"}`, username, password))))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", rancherURL, "/v3/users"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"username":"%s","mustChangePassword": true,"password": "%s","enabled": true,"type":"user"}`, username, password))))
 	if err != nil {
 		return "", "", "", "", err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return "", "", "", "", err
+		return "", "", "", "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
@@ -349,18 +491,21 @@ func Register(username string) (string, string, string, string, error) {
 	if err != nil {
 		return "", "", "", "", err
 	}
+	if resp.StatusCode >= 300 {
+		return "", "", "", "", rancherError("POST", "/v3/users", resp.StatusCode, body)
+	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
 		return "", "", "", "", err
 	}
 
-	err = createGlobalRoleBinding(dt.Id)
+	err = createGlobalRoleBinding(ctx, dt.Id)
 
 	if err != nil {
 		return "", "", "", "", err
 	}
 	// login user
-	id, token, uuid, err := Login(username, password)
+	id, token, uuid, err := Login(ctx, username, password)
 	if err != nil {
 		return "", "", "", "", err
 	}
@@ -370,39 +515,33 @@ func Register(username string) (string, string, string, string, error) {
 
 // Local functions
 
-func createRancherProject(usrProjectName string, plan string) (string, int64, string, error) {
+func createRancherProject(ctx context.Context, usrProjectName string, clusterId string, plan string, annotations map[string]string) (string, int64, string, error) {
 	resourceQuota := genResourceQuotaFromPlan(plan)
 	if resourceQuota == "nil" {
-		return "", 0, "", fmt.Errorf("invalid plan")
+		return "", 0, "", apperror.New(apperror.CodeValidationFailed, "invalid plan")
 	}
 
-	clusterId, err := utils.GetVariable("config", "CLUSTER_ID")
+	annotationsJson, err := json.Marshal(annotations)
 	if err != nil {
 		return "", 0, "", err
 	}
 
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return "", 0, "", err
-	}
+	rancherURL := config.Get().RancherURL
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return "", 0, "", err
-	}
+	rancherToken := config.Get().RancherToken
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", rancherURL, "/v3/projects"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"name":"%s","clusterId":"%s",%s}`, usrProjectName, clusterId, resourceQuota))))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", rancherURL, "/v3/projects"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"name":"%s","clusterId":"%s","annotations":%s,%s}`, usrProjectName, clusterId, annotationsJson, resourceQuota))))
 	if err != nil {
 		return "", 0, "", err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", 0, "", err
+		return "", 0, "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
@@ -410,63 +549,65 @@ func createRancherProject(usrProjectName string, plan string) (string, int64, st
 	dt := RespData{}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return "", 0, "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	if resp.StatusCode >= 300 {
+		return "", 0, "", rancherError("POST", "/v3/projects", resp.StatusCode, body)
 	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
-		log.Fatal(err)
+		return "", 0, "", apperror.Newf(apperror.CodeUpstreamUnavailable, "invalid project response from rancher: %v", err)
+	}
+	if dt.ProjectId == "" {
+		return "", 0, "", apperror.New(apperror.CodeUpstreamUnavailable, "rancher did not return the id of the project")
 	}
 
 	return dt.ProjectId, dt.CreatedTS, dt.UUID, nil
 }
 
-func createGlobalRoleBinding(id string) error {
-
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return err
-	}
-
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return err
-	}
-
-	req2, err := http.NewRequest("POST", fmt.Sprintf("%s%s", rancherURL, "/v3/globalrolebindings"), bytes.NewBuffer([]byte(fmt.Sprintf(`{"type":"globalRoleBinding","globalRoleId":"user","userId":"%s"}`, id))))
-
-	if err != nil {
-		return err
-	}
-
-	req2.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
-
-	client2 := &http.Client{}
-
-	resp2, err := client2.Do(req2)
-
-	if err != nil {
-		return err
-	}
-
-	defer resp2.Body.Close()
-
-	// parse response body
-	dt2 := RespDataCreateUser{}
-	body2, err := ioutil.ReadAll(resp2.Body)
-	if err != nil {
-		return err
-	}
-
-	err = json.Unmarshal(body2, &dt2)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func createGlobalRoleBinding(ctx context.Context, id string) error {
+	return doRancherRequest(ctx, "POST", "/v3/globalrolebindings", map[string]interface{}{
+		"type":         "globalRoleBinding",
+		"globalRoleId": "user",
+		"userId":       id,
+	}, nil)
 }
 
 func genResourceQuotaFromPlan(plan string) string {
 	switch plan {
+	case PlanTrial:
+		return `"namespaceDefaultResourceQuota": {
+			"limit": {
+			"configMaps": "5",
+			"limitsCpu": "500m",
+			"limitsMemory": "1000Mi",
+			"persistentVolumeClaims": "2",
+			"pods": "10",
+			"replicationControllers": "5",
+			"requestsStorage": "5000Mi",
+			"secrets": "10",
+			"services": "10",
+			"servicesLoadBalancers": "0",
+			"servicesNodePorts": "0"
+			}
+			},
+			"resourceQuota": {
+			"limit": {
+			"configMaps": "5",
+			"limitsCpu": "500m",
+			"limitsMemory": "1000Mi",
+			"persistentVolumeClaims": "2",
+			"pods": "10",
+			"replicationControllers": "5",
+			"requestsStorage": "5000Mi",
+			"secrets": "10",
+			"services": "10",
+			"servicesLoadBalancers": "0",
+			"servicesNodePorts": "0"
+			},
+			"usedLimit": { }
+			}
+		`
 	case "Starter":
 		return `"namespaceDefaultResourceQuota": {
 			"limit": {
@@ -579,191 +720,136 @@ func generateRandomString(n int) string {
 	return string(b)
 }
 
-func createGitRepo(name string, url string, branch string) (string, error) {
-	clusterId, err := utils.GetVariable("config", "CLUSTER_ID")
-	if err != nil {
-		return "", err
-	}
-
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return "", err
-	}
-
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/k8s/clusters/%s/v1/catalog.cattle.io.clusterrepos", rancherURL, clusterId), bytes.NewBuffer([]byte(fmt.Sprintf(`{
+func createGitRepo(ctx context.Context, clusterId string, name string, url string, branch string) (string, error) {
+	dt := RespDataCreateGitRepo{}
+	err := doRancherRequest(ctx, "POST", fmt.Sprintf("/k8s/clusters/%s/v1/catalog.cattle.io.clusterrepos", clusterId), map[string]interface{}{
 		"type": "catalog.cattle.io.clusterrepo",
-		"metadata": {
-		  "name": "%s"
+		"metadata": map[string]interface{}{
+			"name": name,
 		},
-		"spec": {
-		  "url": "",
-		  "clientSecret": null,
-		  "gitRepo": "%s",
-		  "gitBranch": "%s"
-		}
-	  }`, name, url, branch))))
-
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
-
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return "", err
-	}
-
-	defer resp.Body.Close()
-
-	// parse response body
-	dt := RespDataCreateGitRepo{}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	err = json.Unmarshal(body, &dt)
+		"spec": map[string]interface{}{
+			"url":          "",
+			"clientSecret": nil,
+			"gitRepo":      url,
+			"gitBranch":    branch,
+		},
+	}, &dt)
 	if err != nil {
 		return "", err
 	}
-
 	return dt.Id, nil
 }
 
-func getProjectsOfUser(userId string, principalIds []string) ([]string, error) {
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
+func getProjectsOfUser(ctx context.Context, userId string, principalIds []string) ([]string, error) {
+	dt := RespDataProjectsByUser{}
+	err := doRancherRequest(ctx, "GET", fmt.Sprintf("/v3/projectroletemplatebindings?userId=%s", userId), nil, &dt)
 	if err != nil {
 		return []string{}, err
 	}
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return []string{}, err
+	// return all the ids
+	res := []string{}
+	for _, v := range dt.Data {
+		res = append(res, v.Id)
 	}
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/projectroletemplatebindings?userId=", userId), nil)
-	if err != nil {
-		return []string{}, err
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
+	return res, nil
+}
 
-	client := &http.Client{}
+func createNamespace(ctx context.Context, projectName string, projectId string, plan string, projectAnnotations map[string]string) (string, error) {
+	nsName := strings.ToLower(projectName) + "-" + generateRandomString(20)
+	return createProjectNamespace(ctx, nsName, projectId, plan, projectAnnotations)
+}
 
-	resp, err := client.Do(req)
+func createProjectNamespace(ctx context.Context, nsName string, projectId string, plan string, projectAnnotations map[string]string) (string, error) {
 
+	clientSet, err := clientFor(projectId)
 	if err != nil {
-		return []string{}, err
+		return "", err
 	}
-	defer resp.Body.Close()
+	nsClient := clientSet.CoreV1().Namespaces()
 
-	// parse response body
-	dt := RespDataProjectsByUser{}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []string{}, err
-	}
-	err = json.Unmarshal(body, &dt)
-	if err != nil {
-		return []string{}, err
+	annotations := map[string]string{}
+	for k, v := range projectAnnotations {
+		annotations[k] = v
 	}
-
-	log.Println(dt)
-
-	if len(dt.Data) > 0 {
-		// return all the ids
-		res := []string{}
-		for _, v := range dt.Data {
-			res = append(res, v.Id)
-		}
-		return res, nil
-
+	annotations[projectIdLabel] = projectId
+	if annotations[stateAnnotation] == "" {
+		annotations[stateAnnotation] = ProjectStateActive
 	}
 
-	return []string{}, nil
-}
-
-func createNamespace(projectName string, projectId string) (string, error) {
-
-	nsClient := auth.MyClientSet.CoreV1().Namespaces()
-
-	nsName := strings.ToLower(projectName) + "-" + generateRandomString(20)
-
 	ns := &v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: nsName,
-			Annotations: map[string]string{
-				"field.cattle.io/projectId": projectId,
-			},
+			Name:        nsName,
+			Annotations: annotations,
 			Labels: map[string]string{
-				"field.cattle.io/projectId": strings.Split(projectId, ":")[1],
+				projectIdLabel: strings.Split(projectId, ":")[1],
+				planLabel:      plan,
 			},
 		},
 	}
 
-	newNs, err := nsClient.Create(context.TODO(), ns, metav1.CreateOptions{})
+	newNs, err := nsClient.Create(ctx, ns, metav1.CreateOptions{})
 	if err != nil {
 		return "", err
 	}
-	return newNs.Name, nil
-}
 
-func checkPayment(token string) (CheckPaymeePaymentResponse, error) {
-	paymeeURL, err := utils.GetVariable("config", "PAYMEE_URL")
+	err = applyNamespaceBaseline(ctx, newNs.Name, projectId, plan)
 	if err != nil {
-		return CheckPaymeePaymentResponse{}, err
+		return "", err
 	}
-	paymeeToken, err := utils.GetVariable("secrets", "PAYMEE_TOKEN")
+
+	err = applyStoredRegistrySecrets(ctx, clientSet, projectId, newNs.Name)
 	if err != nil {
-		return CheckPaymeePaymentResponse{}, err
+		return "", err
 	}
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/payments/%s/check", paymeeURL, token), nil)
+	return newNs.Name, nil
+}
+
+func checkPayment(ctx context.Context, token string) (CheckPaymeePaymentResponse, error) {
+	paymeeURL := config.Get().PaymeeURL
+	paymeeToken := config.Get().PaymeeToken
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/payments/%s/check", paymeeURL, token), nil)
 	if err != nil {
 		return CheckPaymeePaymentResponse{}, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", paymeeToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamPaymee)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return CheckPaymeePaymentResponse{}, err
+		return CheckPaymeePaymentResponse{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
 
+	// a failing Paymee is not a failed payment
+	if resp.StatusCode >= 300 {
+		return CheckPaymeePaymentResponse{}, apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("paymee payment check failed with status %d", resp.StatusCode))
+	}
+
 	// parse response body
 	dt := CheckPaymeePaymentResponse{}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return CheckPaymeePaymentResponse{}, err
+		return CheckPaymeePaymentResponse{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
-		return CheckPaymeePaymentResponse{}, err
+		return CheckPaymeePaymentResponse{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	if dt.Message != "Success" || dt.Data.BuyerId == 0 {
-		return CheckPaymeePaymentResponse{}, errors.New("payment failed")
+		return CheckPaymeePaymentResponse{}, apperror.New(apperror.CodePaymentFailed, "payment failed")
 	}
 
 	return dt, nil
 }
 
-func createBillingAccount(req ReqData, projectId string, t time.Time) (string, error) {
-	billingURL, err := utils.GetVariable("config", "BILLING_URL")
-	if err != nil {
-		return "", err
-	}
+func createBillingAccount(ctx context.Context, req ReqData, projectId string, t time.Time) (string, error) {
+	billingURL := config.Get().BillingURL
 
 	clId := strings.Split(projectId, ":")[0]
 	prId := strings.Split(projectId, ":")[1]
@@ -793,7 +879,7 @@ func createBillingAccount(req ReqData, projectId string, t time.Time) (string, e
 				ProjectId:         prId,
 				ClusterId:         clId,
 				CreationTimeStamp: t,
-				State:             "active",
+				State:             ProjectStateActive,
 				Plan:              req.Plan,
 			},
 		},
@@ -805,7 +891,7 @@ func createBillingAccount(req ReqData, projectId string, t time.Time) (string, e
 		return "", err
 	}
 
-	r, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/CreateBillingAccount", billingURL), bytes.NewBuffer(reqBodyJson))
+	r, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1/CreateBillingAccount", billingURL), bytes.NewBuffer(reqBodyJson))
 
 	if err != nil {
 		return "", err
@@ -813,7 +899,7 @@ func createBillingAccount(req ReqData, projectId string, t time.Time) (string, e
 
 	r.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamBilling)
 
 	resp, err := client.Do(r)
 
@@ -822,7 +908,7 @@ func createBillingAccount(req ReqData, projectId string, t time.Time) (string, e
 	}
 
 	if resp.StatusCode != 201 {
-		return "", errors.New("billing account creation failed")
+		return "", apperror.New(apperror.CodeUpstreamUnavailable, "billing account creation failed")
 	}
 
 	defer resp.Body.Close()
@@ -845,11 +931,8 @@ func createBillingAccount(req ReqData, projectId string, t time.Time) (string, e
 
 }
 
-func addProjectToBillingAccount(billingAccountId uuid.UUID, projectId string, t time.Time, plan string) (string, error) {
-	billingURL, err := utils.GetVariable("config", "BILLING_URL")
-	if err != nil {
-		return "", err
-	}
+func addProjectToBillingAccount(ctx context.Context, billingAccountId uuid.UUID, projectId string, t time.Time, plan string) (string, error) {
+	billingURL := config.Get().BillingURL
 
 	clId := strings.Split(projectId, ":")[0]
 	prId := strings.Split(projectId, ":")[1]
@@ -860,7 +943,7 @@ func addProjectToBillingAccount(billingAccountId uuid.UUID, projectId string, t
 		ClusterId:          clId,
 		CreationTimeStamp:  t,
 		Plan:               plan,
-		State:              "active",
+		State:              ProjectStateActive,
 	}
 
 	b, err := json.Marshal(reqBody)
@@ -868,7 +951,7 @@ func addProjectToBillingAccount(billingAccountId uuid.UUID, projectId string, t
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", billingURL, "/v1/addproject"), bytes.NewBuffer(b))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", billingURL, "/v1/addproject"), bytes.NewBuffer(b))
 
 	if err != nil {
 		return "", err
@@ -876,12 +959,12 @@ func addProjectToBillingAccount(billingAccountId uuid.UUID, projectId string, t
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamBilling)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return "", err
+		return "", apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
@@ -901,3 +984,48 @@ func addProjectToBillingAccount(billingAccountId uuid.UUID, projectId string, t
 
 	return dt.ProjectId, nil
 }
+
+// IsProjectMember tells whether userId provisioned projectId or is bound to
+// it with any role
+func IsProjectMember(ctx context.Context, userId string, projectId string) (bool, error) {
+	if userId == "" {
+		return false, nil
+	}
+	pr, err := getRancherProject(ctx, projectId)
+	if err != nil {
+		return false, err
+	}
+	if pr.Annotations[ownerAnnotation] == userId {
+		return true, nil
+	}
+	bindings, err := listProjectRoleBindings(ctx, projectId, userId)
+	if err != nil {
+		return false, err
+	}
+	return len(bindings) > 0, nil
+}
+
+// IsProjectOwner tells whether userId provisioned projectId or is bound to it
+// as a project owner
+func IsProjectOwner(ctx context.Context, userId string, projectId string) (bool, error) {
+	if userId == "" {
+		return false, nil
+	}
+	pr, err := getRancherProject(ctx, projectId)
+	if err != nil {
+		return false, err
+	}
+	if pr.Annotations[ownerAnnotation] == userId {
+		return true, nil
+	}
+	bindings, err := listProjectRoleBindings(ctx, projectId, userId)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range bindings {
+		if b.RoleTemplateId == "project-owner" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
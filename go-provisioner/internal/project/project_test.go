@@ -0,0 +1,168 @@
+package project
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+)
+
+// loadTestConfig points the configuration at rancherURL, the other upstreams
+// are never called
+func loadTestConfig(t *testing.T, rancherURL string) {
+	t.Helper()
+	t.Setenv("CONFIG_DIR", t.TempDir())
+	t.Setenv("SECRETS_DIR", t.TempDir())
+	t.Setenv("RANCHER_URL", rancherURL)
+	t.Setenv("RANCHER_TOKEN", "token-abcde:secret")
+	t.Setenv("BILLING_URL", "http://billing.invalid")
+	t.Setenv("PAYMEE_URL", "http://paymee.invalid")
+	t.Setenv("PAYMEE_TOKEN", "paymee-token")
+	t.Setenv("CLUSTER_ID", "c-abcde")
+	if err := config.Load(); err != nil {
+		t.Fatalf("cannot load the test configuration: %v", err)
+	}
+}
+
+func TestCreateRancherProjectMalformedResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "not json", status: http.StatusCreated, body: "not json"},
+		{name: "truncated json", status: http.StatusCreated, body: `{"id":"c-abcde:p-`},
+		{name: "no project id", status: http.StatusCreated, body: `{}`},
+		{name: "html error page", status: http.StatusBadGateway, body: "<html>bad gateway</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			loadTestConfig(t, srv.URL)
+
+			_, _, _, err := createRancherProject(context.Background(), "project", "c-abcde", PlanTrial, map[string]string{})
+			if err == nil {
+				t.Fatal("createRancherProject() accepted a malformed response")
+			}
+			if status := apperror.From(err).Status; status < http.StatusInternalServerError {
+				t.Fatalf("createRancherProject() failed with status %d, want a server error: %v", status, err)
+			}
+		})
+	}
+}
+
+func TestListRancherProjectsPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "single page",
+			pages: map[string]string{
+				"": `{"data":[{"id":"c-abcde:p-1"}]}`,
+			},
+			want: []string{"c-abcde:p-1"},
+		},
+		{
+			name: "two pages",
+			pages: map[string]string{
+				"":    `{"data":[{"id":"c-abcde:p-1"}],"pagination":{"next":"{url}/v3/projects?clusterId=c-abcde&marker=p-2","partial":true}}`,
+				"p-2": `{"data":[{"id":"c-abcde:p-2"}]}`,
+			},
+			want: []string{"c-abcde:p-1", "c-abcde:p-2"},
+		},
+		{
+			name: "partial without next page",
+			pages: map[string]string{
+				"": `{"data":[{"id":"c-abcde:p-1"}],"pagination":{"partial":true}}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "next page on another host",
+			pages: map[string]string{
+				"": `{"data":[{"id":"c-abcde:p-1"}],"pagination":{"next":"http://rancher.invalid/v3/projects?marker=p-2","partial":true}}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				page, ok := tt.pages[r.URL.Query().Get("marker")]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(strings.ReplaceAll(page, "{url}", srv.URL)))
+			}))
+			defer srv.Close()
+			loadTestConfig(t, srv.URL)
+
+			projects, err := listRancherProjects(context.Background(), "c-abcde")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("listRancherProjects() returned %d projects, want an error", len(projects))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("listRancherProjects() failed: %v", err)
+			}
+			got := []string{}
+			for _, pr := range projects {
+				got = append(got, pr.Id)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("listRancherProjects() returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPaymentStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   apperror.Code
+	}{
+		{name: "paymee down", status: http.StatusServiceUnavailable, body: "<html>unavailable</html>", want: apperror.CodeUpstreamUnavailable},
+		{name: "throttled", status: http.StatusTooManyRequests, body: `{"message":"slow down"}`, want: apperror.CodeUpstreamUnavailable},
+		{name: "malformed body", status: http.StatusOK, body: "not json", want: apperror.CodeUpstreamUnavailable},
+		{name: "unpaid", status: http.StatusOK, body: `{"message":"Success","data":{"buyer_id":0}}`, want: apperror.CodePaymentFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			loadTestConfig(t, "http://rancher.invalid")
+			t.Setenv("PAYMEE_URL", srv.URL)
+			if err := config.Load(); err != nil {
+				t.Fatalf("cannot load the test configuration: %v", err)
+			}
+
+			_, err := checkPayment(context.Background(), "payment-token")
+			if err == nil {
+				t.Fatal("checkPayment() accepted the payment")
+			}
+			if code := apperror.From(err).Code; code != tt.want {
+				t.Fatalf("checkPayment() failed with %s, want %s: %v", code, tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultQuotaWarningPercent = 80
+
+// rancherQuotaResources maps the keys of a Rancher resource quota to the
+// Kubernetes resource names used in the ResourceQuota objects
+var rancherQuotaResources = map[string]v1.ResourceName{
+	"configMaps":             "configmaps",
+	"limitsCpu":              v1.ResourceLimitsCPU,
+	"limitsMemory":           v1.ResourceLimitsMemory,
+	"persistentVolumeClaims": "persistentvolumeclaims",
+	"pods":                   v1.ResourcePods,
+	"replicationControllers": "replicationcontrollers",
+	"requestsCpu":            v1.ResourceRequestsCPU,
+	"requestsMemory":         v1.ResourceRequestsMemory,
+	"requestsStorage":        v1.ResourceRequestsStorage,
+	"secrets":                "secrets",
+	"services":               "services",
+	"servicesLoadBalancers":  "services.loadbalancers",
+	"servicesNodePorts":      "services.nodeports",
+}
+
+// Exportable functions
+
+// GetProjectQuota returns the hard limits and the current usage of the
+// ResourceQuotas of every namespace in the project, and the project-wide
+// usage against the limits of the Rancher project
+func GetProjectQuota(ctx context.Context, projectId string) (RespDataProjectQuota, error) {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return RespDataProjectQuota{}, err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return RespDataProjectQuota{}, err
+	}
+
+	pr, err := getRancherProject(ctx, projectId)
+	if err != nil {
+		return RespDataProjectQuota{}, err
+	}
+
+	warningPercent := getQuotaWarningPercent()
+
+	res := RespDataProjectQuota{
+		ProjectId:  projectId,
+		Namespaces: []NamespaceQuota{},
+	}
+	projectUsed := v1.ResourceList{}
+
+	for _, ns := range namespaces {
+		hard, used, err := getNamespaceQuota(ctx, clientSet, ns.Name)
+		if err != nil {
+			return RespDataProjectQuota{}, err
+		}
+		for name, quantity := range used {
+			total := projectUsed[name]
+			total.Add(quantity)
+			projectUsed[name] = total
+		}
+		res.Namespaces = append(res.Namespaces, NamespaceQuota{
+			Namespace: ns.Name,
+			Resources: quotaUsage(hard, used, warningPercent),
+		})
+	}
+
+	projectHard := v1.ResourceList{}
+	for key, value := range pr.ResourceQuota.Limit {
+		name, ok := rancherQuotaResources[key]
+		if !ok {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			logger.FromContext(ctx).Warn("invalid quota on a project", "projectId", projectId, "resource", key, "value", value)
+			continue
+		}
+		projectHard[name] = quantity
+	}
+	res.Project = quotaUsage(projectHard, projectUsed, warningPercent)
+
+	return res, nil
+}
+
+// GetPlanQuota returns the project and namespace quotas of a plan
+func GetPlanQuota(plan string) (PlanQuota, error) {
+	resourceQuota := genResourceQuotaFromPlan(plan)
+	if resourceQuota == "nil" {
+		return PlanQuota{}, apperror.Newf(apperror.CodeValidationFailed, "invalid plan %s", plan)
+	}
+	res := PlanQuota{}
+	err := json.Unmarshal([]byte(fmt.Sprintf("{%s}", resourceQuota)), &res)
+	return res, err
+}
+
+// Local functions
+
+// getNamespaceQuota merges the ResourceQuotas of a namespace, the lowest hard
+// limit wins when several quotas constrain the same resource
+func getNamespaceQuota(ctx context.Context, clientSet kubernetes.Interface, namespace string) (v1.ResourceList, v1.ResourceList, error) {
+	quotas, err := clientSet.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hard := v1.ResourceList{}
+	used := v1.ResourceList{}
+	for _, q := range quotas.Items {
+		// the suspension quota is not part of the plan
+		if q.Name == suspendedQuotaName {
+			continue
+		}
+		for name, quantity := range q.Status.Hard {
+			current, ok := hard[name]
+			if !ok || quantity.Cmp(current) < 0 {
+				hard[name] = quantity
+				used[name] = q.Status.Used[name]
+			}
+		}
+	}
+	return hard, used, nil
+}
+
+func quotaUsage(hard v1.ResourceList, used v1.ResourceList, warningPercent float64) []QuotaUsage {
+	res := []QuotaUsage{}
+	for name, h := range hard {
+		u := used[name]
+		percent := 0.0
+		if h.IsZero() {
+			if !u.IsZero() {
+				percent = 100
+			}
+		} else {
+			percent = u.AsApproximateFloat64() / h.AsApproximateFloat64() * 100
+		}
+		res = append(res, QuotaUsage{
+			Resource:  string(name),
+			Hard:      h.String(),
+			Used:      u.String(),
+			Percent:   percent,
+			NearLimit: !h.IsZero() && percent >= warningPercent,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Resource < res[j].Resource
+	})
+	return res
+}
+
+func getQuotaWarningPercent() float64 {
+	return config.Get().Float("QUOTA_WARNING_PERCENT", defaultQuotaWarningPercent)
+}
@@ -0,0 +1,104 @@
+package project
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newQuota(name string, hard v1.ResourceList, used v1.ResourceList) *v1.ResourceQuota {
+	return &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "p-abcde-default"},
+		Status:     v1.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
+
+func TestGetNamespaceQuota(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		newQuota("plan",
+			v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("2"), v1.ResourceRequestsMemory: resource.MustParse("4Gi")},
+			v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("1"), v1.ResourceRequestsMemory: resource.MustParse("1Gi")},
+		),
+		newQuota("stricter",
+			v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("1500m")},
+			v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("500m")},
+		),
+		newQuota(suspendedQuotaName,
+			v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("0"), v1.ResourcePods: resource.MustParse("0")},
+			v1.ResourceList{},
+		),
+	)
+
+	hard, used, err := getNamespaceQuota(context.Background(), clientSet, "p-abcde-default")
+	if err != nil {
+		t.Fatalf("getNamespaceQuota() failed: %v", err)
+	}
+
+	tests := []struct {
+		resource v1.ResourceName
+		hard     string
+		used     string
+	}{
+		{resource: v1.ResourceRequestsCPU, hard: "1500m", used: "500m"},
+		{resource: v1.ResourceRequestsMemory, hard: "4Gi", used: "1Gi"},
+	}
+	for _, tt := range tests {
+		h, u := hard[tt.resource], used[tt.resource]
+		if h.String() != tt.hard || u.String() != tt.used {
+			t.Errorf("%s is %s of %s, want %s of %s", tt.resource, u.String(), h.String(), tt.used, tt.hard)
+		}
+	}
+	if _, ok := hard[v1.ResourcePods]; ok {
+		t.Errorf("the suspension quota is part of the limits: %v", hard)
+	}
+}
+
+func TestQuotaUsage(t *testing.T) {
+	tests := []struct {
+		name          string
+		hard          string
+		used          string
+		wantPercent   float64
+		wantNearLimit bool
+	}{
+		{name: "unused", hard: "2", used: "0", wantPercent: 0},
+		{name: "below the warning", hard: "2", used: "1", wantPercent: 50},
+		{name: "at the warning", hard: "2", used: "1600m", wantPercent: 80, wantNearLimit: true},
+		{name: "full", hard: "4Gi", used: "4Gi", wantPercent: 100, wantNearLimit: true},
+		{name: "zero limit unused", hard: "0", used: "0", wantPercent: 0},
+		{name: "zero limit used", hard: "0", used: "1", wantPercent: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := quotaUsage(
+				v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(tt.hard)},
+				v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse(tt.used)},
+				80,
+			)
+			if len(res) != 1 {
+				t.Fatalf("quotaUsage() returned %d resources, want 1", len(res))
+			}
+			if res[0].Percent != tt.wantPercent || res[0].NearLimit != tt.wantNearLimit {
+				t.Fatalf("quotaUsage() is %v%% near limit %v, want %v%% near limit %v", res[0].Percent, res[0].NearLimit, tt.wantPercent, tt.wantNearLimit)
+			}
+		})
+	}
+}
+
+func TestQuotaUsageSorted(t *testing.T) {
+	res := quotaUsage(v1.ResourceList{
+		v1.ResourceRequestsStorage: resource.MustParse("10Gi"),
+		v1.ResourceLimitsCPU:       resource.MustParse("2"),
+		v1.ResourcePods:            resource.MustParse("10"),
+	}, v1.ResourceList{}, 80)
+	want := []string{"limits.cpu", "pods", "requests.storage"}
+	for i, name := range want {
+		if res[i].Resource != name {
+			t.Fatalf("resource %d is %s, want %s", i, res[i].Resource, name)
+		}
+	}
+}
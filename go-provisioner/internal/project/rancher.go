@@ -0,0 +1,184 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+)
+
+// doRancherRequest sends an authenticated request to the Rancher API and
+// decodes the json response into out when out is not nil
+func doRancherRequest(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	return doRancherRequestWithToken(ctx, config.Get().RancherToken, method, path, body, out)
+}
+
+// doRancherRequestWithToken is doRancherRequest authenticated with the token
+// of a user instead of the provisioner token
+func doRancherRequestWithToken(ctx context.Context, rancherToken string, method string, path string, body interface{}, out interface{}) error {
+	rancherURL := config.Get().RancherURL
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", rancherURL, path), reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
+	req.Header.Set("Content-Type", "application/json")
+
+	client := metrics.Client(metrics.UpstreamRancher)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		return rancherError(method, path, resp.StatusCode, respBody)
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// rancherError maps a failed Rancher response to an apperror, keeping the
+// message of the Rancher error body when there is one
+func rancherError(method string, path string, status int, body []byte) error {
+	dt := RespDataRancherError{}
+	if json.Unmarshal(body, &dt) == nil && dt.Message != "" {
+		return apperror.FromHTTPStatus(status, fmt.Sprintf("rancher %s %s failed with status %d: %s", method, path, status, dt.Message))
+	}
+	return apperror.FromHTTPStatus(status, fmt.Sprintf("rancher %s %s failed with status %d", method, path, status))
+}
+
+// listRancherProjects lists the projects of clusterId, following the pages.
+// The gc deletes what is not listed, a partial list must never be returned.
+func listRancherProjects(ctx context.Context, clusterId string) ([]RancherProject, error) {
+	rancherURL := config.Get().RancherURL
+	res := []RancherProject{}
+	path := fmt.Sprintf("/v3/projects?clusterId=%s", url.QueryEscape(clusterId))
+	for path != "" {
+		dt := RespDataRancherProjects{}
+		err := doRancherRequest(ctx, "GET", path, nil, &dt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, dt.Data...)
+
+		path = ""
+		if next := dt.Pagination.Next; next != "" {
+			// the provisioner token is only sent to RANCHER_URL
+			if !strings.HasPrefix(next, rancherURL+"/") {
+				return nil, apperror.Newf(apperror.CodeUpstreamUnavailable, "rancher returned the next page %s outside of RANCHER_URL", next)
+			}
+			path = strings.TrimPrefix(next, rancherURL)
+		} else if dt.Pagination.Partial {
+			return nil, apperror.New(apperror.CodeUpstreamUnavailable, "rancher returned a partial project list without a next page")
+		}
+	}
+	return res, nil
+}
+
+// listAllRancherProjects lists the projects of every cluster
+func listAllRancherProjects(ctx context.Context) ([]RancherProject, error) {
+	clusters, err := cluster.List()
+	if err != nil {
+		return nil, err
+	}
+	res := []RancherProject{}
+	for _, c := range clusters {
+		projects, err := listRancherProjects(ctx, c.RancherClusterId)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, projects...)
+	}
+	return res, nil
+}
+
+func getRancherProject(ctx context.Context, projectId string) (RancherProject, error) {
+	dt := RancherProject{}
+	err := doRancherRequest(ctx, "GET", fmt.Sprintf("/v3/projects/%s", projectId), nil, &dt)
+	return dt, err
+}
+
+// markProvisioned records on projectId that its provisioning is complete
+func markProvisioned(ctx context.Context, projectId string, now time.Time) error {
+	pr, err := getRancherProject(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	annotations := pr.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[provisionedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	return doRancherRequest(ctx, "PUT", fmt.Sprintf("/v3/projects/%s", projectId), map[string]interface{}{
+		"annotations": annotations,
+	}, nil)
+}
+
+func deleteRancherProject(ctx context.Context, projectId string) error {
+	return doRancherRequest(ctx, "DELETE", fmt.Sprintf("/v3/projects/%s", projectId), nil, nil)
+}
+
+func getRancherUser(ctx context.Context, userId string) (RancherUser, error) {
+	dt := RancherUser{}
+	err := doRancherRequest(ctx, "GET", fmt.Sprintf("/v3/users/%s", userId), nil, &dt)
+	return dt, err
+}
+
+// getUserFromToken returns the Rancher user owning token
+func getUserFromToken(ctx context.Context, token string) (RancherUser, error) {
+	dt := RespDataRancherUsers{}
+	err := doRancherRequestWithToken(ctx, token, "GET", "/v3/users?me=true", nil, &dt)
+	if err != nil {
+		return RancherUser{}, err
+	}
+	if len(dt.Data) == 0 {
+		return RancherUser{}, apperror.New(apperror.CodeNotFound, "user not found")
+	}
+	return dt.Data[0], nil
+}
+
+func listProjectRoleBindings(ctx context.Context, projectId string, userId string) ([]ProjectRoleBinding, error) {
+	path := fmt.Sprintf("/v3/projectroletemplatebindings?projectId=%s", projectId)
+	if userId != "" {
+		path = fmt.Sprintf("%s&userId=%s", path, userId)
+	}
+	dt := RespDataProjectRoleBindings{}
+	err := doRancherRequest(ctx, "GET", path, nil, &dt)
+	if err != nil {
+		return nil, err
+	}
+	return dt.Data, nil
+}
@@ -0,0 +1,324 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultReconcileInterval = 10 * time.Minute
+	defaultReconcileGrace    = 15 * time.Minute
+	planQuotaName            = "plan-quota"
+)
+
+// Exportable functions
+
+// StartReconciler compares the projects created by the provisioner with the
+// state they should be in and repairs the drift. It blocks until ctx is done,
+// a running reconciliation is finished first, and should be run in its own
+// goroutine.
+func StartReconciler(ctx context.Context) {
+	interval := config.Get().Duration("RECONCILE_INTERVAL", defaultReconcileInterval)
+	for {
+		// the shutdown waits for the running pass, it is not cancelled with ctx
+		err := ReconcileProjects(context.Background())
+		if err != nil {
+			logger.Error("reconciler: reconciliation failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ReconcileProjects runs one reconciliation of every managed project
+func ReconcileProjects(ctx context.Context) error {
+	projects, err := listAllRancherProjects(ctx)
+	if err != nil {
+		return err
+	}
+	active := map[string]int{}
+	for _, pr := range projects {
+		if pr.Annotations[managedByAnnotation] != managedBy {
+			continue
+		}
+		if pr.State == "active" {
+			active[pr.Annotations[planAnnotation]]++
+		}
+		// the provisionings still running or that failed halfway are left
+		// to ProvisionProject and to the gc
+		if !isProvisioned(pr, time.Now()) {
+			continue
+		}
+		err = reconcileProject(ctx, pr)
+		if err != nil {
+			logger.FromContext(ctx).Error("reconciler: cannot reconcile a project", "projectId", pr.Id, "error", err)
+		}
+	}
+	metrics.SetActiveProjects(active)
+	return nil
+}
+
+// Local functions
+
+// isProvisioned tells whether ProvisionProject finished pr at least
+// RECONCILE_GRACE_PERIOD ago
+func isProvisioned(pr RancherProject, now time.Time) bool {
+	provisionedAt, err := time.Parse(time.RFC3339, pr.Annotations[provisionedAtAnnotation])
+	if err != nil {
+		return false
+	}
+	return now.Sub(provisionedAt) >= config.Get().Duration("RECONCILE_GRACE_PERIOD", defaultReconcileGrace)
+}
+
+func reconcileProject(ctx context.Context, pr RancherProject) error {
+	plan := pr.Annotations[planAnnotation]
+	planQuota, err := GetPlanQuota(plan)
+	if err != nil {
+		return err
+	}
+
+	if !quotaLimitsEqual(pr.ResourceQuota.Limit, planQuota.ResourceQuota.Limit) {
+		logCorrection(ctx, pr.Id, "project quota does not match the %s plan, restoring it", plan)
+		err = updateRancherProjectPlan(ctx, pr, plan)
+		if err != nil {
+			return err
+		}
+	}
+
+	clientSet, err := clientFor(pr.Id)
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := listProjectNamespaces(ctx, pr.Id)
+	if err != nil {
+		// only a successful listing tells that the namespaces are gone, any
+		// other failure would create duplicates
+		if apperror.From(err).Code != apperror.CodeNotFound {
+			return err
+		}
+		// every project keeps at least one namespace
+		logCorrection(ctx, pr.Id, "no namespace left, creating one")
+		_, err = createNamespace(ctx, pr.Name, pr.Id, plan, namespaceAnnotations(pr.Annotations))
+		return err
+	}
+
+	for _, ns := range namespaces {
+		err = reconcileNamespace(ctx, clientSet, pr, ns, plan, planQuota)
+		if err != nil {
+			return fmt.Errorf("namespace %s: %w", ns.Name, err)
+		}
+	}
+
+	return reconcileOwnerBinding(ctx, pr)
+}
+
+func reconcileNamespace(ctx context.Context, clientSet kubernetes.Interface, pr RancherProject, ns v1.Namespace, plan string, planQuota PlanQuota) error {
+	expectedLabels := map[string]string{
+		projectIdLabel: strings.Split(pr.Id, ":")[1],
+		planLabel:      plan,
+	}
+	expectedAnnotations := namespaceAnnotations(pr.Annotations)
+	expectedAnnotations[projectIdLabel] = pr.Id
+
+	changed := false
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for k, v := range expectedLabels {
+		if ns.Labels[k] != v {
+			logCorrection(ctx, pr.Id, "namespace %s label %s is %q instead of %q", ns.Name, k, ns.Labels[k], v)
+			ns.Labels[k] = v
+			changed = true
+		}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	for k, v := range expectedAnnotations {
+		// the state and the trial markers are owned by the namespace
+		if k == stateAnnotation || k == trialWarnedAtAnnotation {
+			continue
+		}
+		if ns.Annotations[k] != v {
+			logCorrection(ctx, pr.Id, "namespace %s annotation %s is %q instead of %q", ns.Name, k, ns.Annotations[k], v)
+			ns.Annotations[k] = v
+			changed = true
+		}
+	}
+	if changed {
+		_, err := clientSet.CoreV1().Namespaces().Update(ctx, &ns, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	missing, err := missingBaseline(ctx, clientSet, pr.Id, ns.Name)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		logCorrection(ctx, pr.Id, "namespace %s is missing or has an outdated %s, applying the baseline", ns.Name, strings.Join(missing, ", "))
+		err = applyNamespaceBaseline(ctx, ns.Name, pr.Id, plan)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = reconcileNamespaceQuota(ctx, clientSet, pr.Id, ns.Name, planQuota)
+	if err != nil {
+		return err
+	}
+
+	if ns.Annotations[stateAnnotation] == ProjectStateSuspended {
+		_, err = clientSet.CoreV1().ResourceQuotas(ns.Name).Get(ctx, suspendedQuotaName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			logCorrection(ctx, pr.Id, "namespace %s is suspended without its quota, restoring it", ns.Name)
+			return createSuspendedQuota(ctx, clientSet, ns.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// missingBaseline returns the enabled baseline policies whose objects are
+// gone, and the network policy when its rules are outdated
+func missingBaseline(ctx context.Context, clientSet kubernetes.Interface, projectId string, nsName string) ([]string, error) {
+	missing := []string{}
+	for _, policy := range getBaselinePolicies() {
+		var err error
+		switch policy {
+		case baselineNetworkPolicy:
+			var np *networkingv1.NetworkPolicy
+			np, err = clientSet.NetworkingV1().NetworkPolicies(nsName).Get(ctx, networkPolicyName, metav1.GetOptions{})
+			if err == nil && !equality.Semantic.DeepEqual(np.Spec, networkPolicySpec(projectId)) {
+				missing = append(missing, policy)
+				continue
+			}
+		case baselineLimitRange:
+			_, err = clientSet.CoreV1().LimitRanges(nsName).Get(ctx, limitRangeName, metav1.GetOptions{})
+		default:
+			continue
+		}
+		if apierrors.IsNotFound(err) {
+			missing = append(missing, policy)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// reconcileNamespaceQuota recreates the namespace quota of the plan when every
+// quota of the namespace was deleted
+func reconcileNamespaceQuota(ctx context.Context, clientSet kubernetes.Interface, projectId string, nsName string, planQuota PlanQuota) error {
+	client := clientSet.CoreV1().ResourceQuotas(nsName)
+	quotas, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas.Items {
+		if q.Name != suspendedQuotaName {
+			return nil
+		}
+	}
+
+	logCorrection(ctx, projectId, "namespace %s has no quota, creating the plan quota", nsName)
+	hard := v1.ResourceList{}
+	for key, value := range planQuota.NamespaceDefaultResourceQuota.Limit {
+		name, ok := rancherQuotaResources[key]
+		if !ok {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return err
+		}
+		hard[name] = quantity
+	}
+	_, err = client.Create(ctx, &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: planQuotaName},
+		Spec:       v1.ResourceQuotaSpec{Hard: hard},
+	}, metav1.CreateOptions{})
+	return err
+}
+
+// reconcileOwnerBinding gives the owner back its membership of the project
+func reconcileOwnerBinding(ctx context.Context, pr RancherProject) error {
+	owner := pr.Annotations[ownerAnnotation]
+	if owner == "" {
+		return nil
+	}
+	bindings, err := listProjectRoleBindings(ctx, pr.Id, owner)
+	if err != nil {
+		return err
+	}
+	if len(bindings) > 0 {
+		return nil
+	}
+	logCorrection(ctx, pr.Id, "owner %s is not a member anymore, adding it back", owner)
+	_, err = AddUserToProject(ctx, owner, pr.Id)
+	return err
+}
+
+// quotaLimitsEqual compares the quantities of two quota limits, "1000m" and
+// "1" are the same limit
+func quotaLimitsEqual(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		other, ok := b[key]
+		if !ok {
+			return false
+		}
+		if value == other {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return false
+		}
+		otherQ, err := resource.ParseQuantity(other)
+		if err != nil {
+			return false
+		}
+		if q.Cmp(otherQ) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// namespaceAnnotations returns the annotations of a Rancher project that are
+// copied to its namespaces
+func namespaceAnnotations(annotations map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range annotations {
+		if strings.HasPrefix(k, "creometry.com/") && k != managedByAnnotation {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func logCorrection(ctx context.Context, projectId string, format string, args ...interface{}) {
+	logger.FromContext(ctx).Info("reconciler: corrected a project", "projectId", projectId, "correction", fmt.Sprintf(format, args...))
+}
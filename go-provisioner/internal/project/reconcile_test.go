@@ -0,0 +1,63 @@
+package project
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaLimitsEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a    map[string]string
+		b    map[string]string
+		want bool
+	}{
+		{name: "empty", a: map[string]string{}, b: map[string]string{}, want: true},
+		{name: "same strings", a: map[string]string{"limitsCpu": "2000m"}, b: map[string]string{"limitsCpu": "2000m"}, want: true},
+		{name: "same quantity", a: map[string]string{"limitsCpu": "1000m"}, b: map[string]string{"limitsCpu": "1"}, want: true},
+		{name: "same memory", a: map[string]string{"limitsMemory": "1024Mi"}, b: map[string]string{"limitsMemory": "1Gi"}, want: true},
+		{name: "other quantity", a: map[string]string{"limitsCpu": "1000m"}, b: map[string]string{"limitsCpu": "2"}},
+		{name: "missing key", a: map[string]string{"limitsCpu": "1"}, b: map[string]string{"limitsMemory": "1"}},
+		{name: "extra key", a: map[string]string{"limitsCpu": "1"}, b: map[string]string{"limitsCpu": "1", "pods": "10"}},
+		{name: "invalid quantity", a: map[string]string{"limitsCpu": "one"}, b: map[string]string{"limitsCpu": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotaLimitsEqual(tt.a, tt.b); got != tt.want {
+				t.Fatalf("quotaLimitsEqual(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := quotaLimitsEqual(tt.b, tt.a); got != tt.want {
+				t.Fatalf("quotaLimitsEqual(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsProvisioned(t *testing.T) {
+	t.Setenv("RECONCILE_GRACE_PERIOD", "15m")
+	loadTestConfig(t, "http://rancher.invalid")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		provisionedAt string
+		want          bool
+	}{
+		{name: "never marked", provisionedAt: ""},
+		{name: "invalid timestamp", provisionedAt: "yesterday"},
+		{name: "within the grace period", provisionedAt: now.Add(-5 * time.Minute).Format(time.RFC3339)},
+		{name: "at the end of the grace period", provisionedAt: now.Add(-15 * time.Minute).Format(time.RFC3339), want: true},
+		{name: "long ago", provisionedAt: now.Add(-48 * time.Hour).Format(time.RFC3339), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := RancherProject{Annotations: map[string]string{}}
+			if tt.provisionedAt != "" {
+				pr.Annotations[provisionedAtAnnotation] = tt.provisionedAt
+			}
+			if got := isProvisioned(pr, now); got != tt.want {
+				t.Fatalf("isProvisioned() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package project
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	registryLabel              = "creometry.com/registry"
+	registryServerAnnotation   = "creometry.com/registry-server"
+	registryUsernameAnnotation = "creometry.com/registry-username"
+	registryRotatedAnnotation  = "creometry.com/rotated-at"
+	registryProjectLabel       = "creometry.com/registry-project"
+	registrySecretPrefix       = "registry-"
+)
+
+// Exportable functions
+
+// ListRegistries returns the registries stored for the project without their
+// passwords
+func ListRegistries(ctx context.Context, projectId string) ([]Registry, error) {
+	secrets, err := listStoredRegistrySecrets(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+	res := []Registry{}
+	for _, s := range secrets {
+		res = append(res, Registry{
+			Name:      s.Labels[registryLabel],
+			Server:    s.Annotations[registryServerAnnotation],
+			Username:  s.Annotations[registryUsernameAnnotation],
+			RotatedAt: s.Annotations[registryRotatedAnnotation],
+		})
+	}
+	return res, nil
+}
+
+// SetRegistry creates the credentials of a registry in every namespace of the
+// project and adds them to the default ServiceAccounts. An existing registry
+// is a conflict unless overwrite is set, its credentials are then rotated.
+func SetRegistry(ctx context.Context, projectId string, req ReqDataRegistry, overwrite bool) (Registry, error) {
+	if errs := validation.IsDNS1123Label(req.Name); len(errs) > 0 {
+		return Registry{}, apperror.Newf(apperror.CodeValidationFailed, "invalid registry name %s: %s", req.Name, strings.Join(errs, ", "))
+	}
+
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return Registry{}, err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	if !overwrite {
+		exists, err := registryExists(ctx, clientSet, projectId, namespaces[0].Name, req.Name)
+		if err != nil {
+			return Registry{}, err
+		}
+		if exists {
+			return Registry{}, apperror.Newf(apperror.CodeConflict, "registry %s already exists", req.Name)
+		}
+	}
+
+	secret, err := genRegistrySecret(req)
+	if err != nil {
+		return Registry{}, err
+	}
+	err = storeRegistrySecret(ctx, projectId, secret)
+	if err != nil {
+		return Registry{}, err
+	}
+	for _, ns := range namespaces {
+		err = applyRegistrySecret(ctx, clientSet, ns.Name, secret)
+		if err != nil {
+			return Registry{}, err
+		}
+	}
+
+	return Registry{
+		Name:      req.Name,
+		Server:    req.Server,
+		Username:  req.Username,
+		RotatedAt: secret.Annotations[registryRotatedAnnotation],
+	}, nil
+}
+
+// DeleteRegistry removes the registry secret from every namespace of the
+// project and from their default ServiceAccounts
+func DeleteRegistry(ctx context.Context, projectId string, name string) error {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	secretName := registrySecretPrefix + name
+	for _, ns := range namespaces {
+		err = removeImagePullSecret(ctx, clientSet, ns.Name, secretName)
+		if err != nil {
+			return err
+		}
+		err = clientSet.CoreV1().Secrets(ns.Name).Delete(ctx, secretName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	err = registryStore().Delete(ctx, storedRegistryName(projectId, name), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Local functions
+
+func genRegistrySecret(req ReqDataRegistry) (*v1.Secret, error) {
+	dockerConfig := map[string]interface{}{
+		"auths": map[string]interface{}{
+			req.Server: map[string]string{
+				"username": req.Username,
+				"password": req.Password,
+				"email":    req.Email,
+				"auth":     base64.StdEncoding.EncodeToString([]byte(req.Username + ":" + req.Password)),
+			},
+		},
+	}
+	b, err := json.Marshal(dockerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: registrySecretPrefix + req.Name,
+			Labels: map[string]string{
+				registryLabel: req.Name,
+			},
+			Annotations: map[string]string{
+				registryServerAnnotation:   req.Server,
+				registryUsernameAnnotation: req.Username,
+				registryRotatedAnnotation:  time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			v1.DockerConfigJsonKey: b,
+		},
+	}, nil
+}
+
+// applyRegistrySecret creates or updates the secret in namespace and makes
+// sure the default ServiceAccount uses it
+func applyRegistrySecret(ctx context.Context, clientSet kubernetes.Interface, namespace string, secret *v1.Secret) error {
+	client := clientSet.CoreV1().Secrets(namespace)
+
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   namespace,
+			Labels:      secret.Labels,
+			Annotations: secret.Annotations,
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+
+	existing, err := client.Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, s, metav1.CreateOptions{})
+	} else if err == nil {
+		existing.Labels = s.Labels
+		existing.Annotations = s.Annotations
+		existing.Data = s.Data
+		_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	return addImagePullSecret(ctx, clientSet, namespace, s.Name)
+}
+
+// registryExists tells whether the registry name is known to the project,
+// either stored by the provisioner or set in its first namespace
+func registryExists(ctx context.Context, clientSet kubernetes.Interface, projectId string, namespace string, name string) (bool, error) {
+	_, err := registryStore().Get(ctx, storedRegistryName(projectId, name), metav1.GetOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	_, err = clientSet.CoreV1().Secrets(namespace).Get(ctx, registrySecretPrefix+name, metav1.GetOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// registryStore returns the secrets of the namespace of the provisioner. The
+// registries are kept there too, so that the namespaces created later get
+// them even when every other namespace of the project is gone.
+func registryStore() corev1.SecretInterface {
+	namespace := config.Get().RegistriesNamespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return auth.MyClientSet.CoreV1().Secrets(namespace)
+}
+
+// storedRegistryName returns the name of the stored copy of the registry name
+// of projectId, the project ids are only unique within their cluster
+func storedRegistryName(projectId string, name string) string {
+	return registrySecretPrefix + strings.ReplaceAll(projectId, ":", "-") + "-" + name
+}
+
+// storeRegistrySecret creates or updates the stored copy of secret
+func storeRegistrySecret(ctx context.Context, projectId string, secret *v1.Secret) error {
+	client := registryStore()
+	labels := map[string]string{
+		registryProjectLabel: strings.ReplaceAll(projectId, ":", "."),
+	}
+	for k, v := range secret.Labels {
+		labels[k] = v
+	}
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        storedRegistryName(projectId, secret.Labels[registryLabel]),
+			Labels:      labels,
+			Annotations: secret.Annotations,
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+
+	existing, err := client.Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, s, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Labels = s.Labels
+	existing.Annotations = s.Annotations
+	existing.Data = s.Data
+	_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// listStoredRegistrySecrets returns the stored copies of the registries of
+// projectId
+func listStoredRegistrySecrets(ctx context.Context, projectId string) ([]v1.Secret, error) {
+	list, err := registryStore().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", registryProjectLabel, strings.ReplaceAll(projectId, ":", ".")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// applyStoredRegistrySecrets gives namespace the registries stored for
+// projectId
+func applyStoredRegistrySecrets(ctx context.Context, clientSet kubernetes.Interface, projectId string, namespace string) error {
+	secrets, err := listStoredRegistrySecrets(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	for _, s := range secrets {
+		name := s.Labels[registryLabel]
+		err = applyRegistrySecret(ctx, clientSet, namespace, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        registrySecretPrefix + name,
+				Labels:      map[string]string{registryLabel: name},
+				Annotations: s.Annotations,
+			},
+			Type: s.Type,
+			Data: s.Data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addImagePullSecret(ctx context.Context, clientSet kubernetes.Interface, namespace string, secretName string) error {
+	client := clientSet.CoreV1().ServiceAccounts(namespace)
+
+	sa, err := client.Get(ctx, "default", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, &v1.ServiceAccount{
+			ObjectMeta:       metav1.ObjectMeta{Name: "default"},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: secretName}},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return addImagePullSecret(ctx, clientSet, namespace, secretName)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == secretName {
+			return nil
+		}
+	}
+	sa.ImagePullSecrets = append(sa.ImagePullSecrets, v1.LocalObjectReference{Name: secretName})
+	_, err = client.Update(ctx, sa, metav1.UpdateOptions{})
+	return err
+}
+
+func removeImagePullSecret(ctx context.Context, clientSet kubernetes.Interface, namespace string, secretName string) error {
+	client := clientSet.CoreV1().ServiceAccounts(namespace)
+
+	sa, err := client.Get(ctx, "default", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	refs := []v1.LocalObjectReference{}
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name != secretName {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == len(sa.ImagePullSecrets) {
+		return nil
+	}
+	sa.ImagePullSecrets = refs
+	_, err = client.Update(ctx, sa, metav1.UpdateOptions{})
+	return err
+}
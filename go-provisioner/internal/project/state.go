@@ -0,0 +1,381 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Exportable functions
+
+// SuspendProject scales every workload of the project to zero and blocks new
+// pods with a zero ResourceQuota. The previous replica counts are kept in an
+// annotation on each workload so ReactivateProject can restore them. The
+// billing service is told about the new state.
+func SuspendProject(ctx context.Context, projectId string) (RespDataProjectState, error) {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+
+	for _, ns := range namespaces {
+		err = scaleDownWorkloads(ctx, clientSet, ns.Name)
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+		err = createSuspendedQuota(ctx, clientSet, ns.Name)
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+		err = setNamespaceState(ctx, clientSet, ns.Name, ProjectStateSuspended)
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+	}
+	notifyBilling(ctx, projectId, ReqDataUpdateBillingProject{State: ProjectStateSuspended})
+
+	return RespDataProjectState{
+		ProjectId:  projectId,
+		State:      ProjectStateSuspended,
+		Namespaces: namespaceNames(namespaces),
+	}, nil
+}
+
+// ReactivateProject removes the zero ResourceQuota and scales the workloads
+// back to the replica counts recorded by SuspendProject. The billing service
+// is told about the new state.
+func ReactivateProject(ctx context.Context, projectId string) (RespDataProjectState, error) {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+
+	for _, ns := range namespaces {
+		err = deleteSuspendedQuota(ctx, clientSet, ns.Name)
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+		err = restoreWorkloads(ctx, clientSet, ns.Name)
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+		err = setNamespaceState(ctx, clientSet, ns.Name, ProjectStateActive)
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+	}
+	notifyBilling(ctx, projectId, ReqDataUpdateBillingProject{State: ProjectStateActive})
+
+	return RespDataProjectState{
+		ProjectId:  projectId,
+		State:      ProjectStateActive,
+		Namespaces: namespaceNames(namespaces),
+	}, nil
+}
+
+// Local functions
+
+// notifyBilling reports a change of projectId to the billing service. The
+// change is already applied to the cluster, a failure is logged rather than
+// returned so that the caller does not retry it.
+func notifyBilling(ctx context.Context, projectId string, change ReqDataUpdateBillingProject) {
+	err := updateBillingProject(ctx, projectId, change)
+	if err != nil {
+		logger.FromContext(ctx).Error("cannot report a project change to the billing service", "projectId", projectId, "state", change.State, "plan", change.Plan, "error", err)
+	}
+}
+
+func updateBillingProject(ctx context.Context, projectId string, change ReqDataUpdateBillingProject) error {
+	projectURL, err := billingProjectURL(projectId)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "PATCH", projectURL, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := metrics.Client(metrics.UpstreamBilling).Do(req)
+	if err != nil {
+		return apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("billing update of project %s failed with status %d", projectId, resp.StatusCode))
+	}
+	return nil
+}
+
+// deleteBillingProject deletes the billing record of projectId, a record that
+// is already gone is not an error
+func deleteBillingProject(ctx context.Context, projectId string) error {
+	projectURL, err := billingProjectURL(projectId)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "DELETE", projectURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := metrics.Client(metrics.UpstreamBilling).Do(req)
+	if err != nil {
+		return apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("billing deletion of project %s failed with status %d", projectId, resp.StatusCode))
+	}
+	return nil
+}
+
+// clientFor returns the clientSet of the cluster holding the project
+func clientFor(projectId string) (kubernetes.Interface, error) {
+	c, err := cluster.ForProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.ClientSet(c.RancherClusterId)
+}
+
+// listAllNamespaces lists the namespaces matching selector in every cluster,
+// the clusters that cannot be reached are logged and skipped
+func listAllNamespaces(ctx context.Context, selector string) ([]v1.Namespace, error) {
+	clusters, err := cluster.List()
+	if err != nil {
+		return nil, err
+	}
+	res := []v1.Namespace{}
+	for _, c := range clusters {
+		clientSet, err := cluster.ClientSet(c.RancherClusterId)
+		if err == nil {
+			var list *v1.NamespaceList
+			list, err = clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+				LabelSelector: selector,
+			})
+			if err == nil {
+				res = append(res, list.Items...)
+			}
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("cannot list the namespaces of a cluster", "cluster", c.Id, "error", err)
+		}
+	}
+	return res, nil
+}
+
+func listProjectNamespaces(ctx context.Context, projectId string) ([]v1.Namespace, error) {
+	parts := strings.Split(projectId, ":")
+	if len(parts) != 2 {
+		return nil, apperror.Newf(apperror.CodeValidationFailed, "invalid projectId %s", projectId)
+	}
+
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", projectIdLabel, parts[1]),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, apperror.Newf(apperror.CodeNotFound, "no namespaces found for project %s", projectId)
+	}
+	return list.Items, nil
+}
+
+func namespaceNames(namespaces []v1.Namespace) []string {
+	res := []string{}
+	for _, ns := range namespaces {
+		res = append(res, ns.Name)
+	}
+	return res
+}
+
+func scaleDownWorkloads(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	deployClient := clientSet.AppsV1().Deployments(namespace)
+	deployments, err := deployClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments.Items {
+		// keep the first recorded value if the project is suspended twice
+		if _, ok := d.Annotations[previousReplicasAnnotation]; ok {
+			continue
+		}
+		if d.Annotations == nil {
+			d.Annotations = map[string]string{}
+		}
+		d.Annotations[previousReplicasAnnotation] = strconv.Itoa(int(replicasOrDefault(d.Spec.Replicas)))
+		zero := int32(0)
+		d.Spec.Replicas = &zero
+		_, err = deployClient.Update(ctx, &d, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	stsClient := clientSet.AppsV1().StatefulSets(namespace)
+	statefulSets, err := stsClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, s := range statefulSets.Items {
+		if _, ok := s.Annotations[previousReplicasAnnotation]; ok {
+			continue
+		}
+		if s.Annotations == nil {
+			s.Annotations = map[string]string{}
+		}
+		s.Annotations[previousReplicasAnnotation] = strconv.Itoa(int(replicasOrDefault(s.Spec.Replicas)))
+		zero := int32(0)
+		s.Spec.Replicas = &zero
+		_, err = stsClient.Update(ctx, &s, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restoreWorkloads(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	deployClient := clientSet.AppsV1().Deployments(namespace)
+	deployments, err := deployClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments.Items {
+		replicas, ok, err := previousReplicas(d.Annotations)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		delete(d.Annotations, previousReplicasAnnotation)
+		d.Spec.Replicas = &replicas
+		_, err = deployClient.Update(ctx, &d, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	stsClient := clientSet.AppsV1().StatefulSets(namespace)
+	statefulSets, err := stsClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, s := range statefulSets.Items {
+		replicas, ok, err := previousReplicas(s.Annotations)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		delete(s.Annotations, previousReplicasAnnotation)
+		s.Spec.Replicas = &replicas
+		_, err = stsClient.Update(ctx, &s, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	// a nil replica count defaults to 1 on the api server
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func previousReplicas(annotations map[string]string) (int32, bool, error) {
+	value, ok := annotations[previousReplicasAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s annotation: %s", previousReplicasAnnotation, value)
+	}
+	return int32(replicas), true, nil
+}
+
+func createSuspendedQuota(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	quotaClient := clientSet.CoreV1().ResourceQuotas(namespace)
+
+	zero := resource.MustParse("0")
+	quota := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: suspendedQuotaName,
+		},
+		Spec: v1.ResourceQuotaSpec{
+			Hard: v1.ResourceList{
+				v1.ResourcePods:           zero,
+				v1.ResourceRequestsCPU:    zero,
+				v1.ResourceRequestsMemory: zero,
+				v1.ResourceLimitsCPU:      zero,
+				v1.ResourceLimitsMemory:   zero,
+			},
+		},
+	}
+
+	_, err := quotaClient.Create(ctx, quota, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func deleteSuspendedQuota(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	err := clientSet.CoreV1().ResourceQuotas(namespace).Delete(ctx, suspendedQuotaName, metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func setNamespaceState(ctx context.Context, clientSet kubernetes.Interface, namespace string, state string) error {
+	nsClient := clientSet.CoreV1().Namespaces()
+	ns, err := nsClient.Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[stateAnnotation] = state
+	_, err = nsClient.Update(ctx, ns, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,106 @@
+package project
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestSuspendAndReactivateProject(t *testing.T) {
+	logger.SetOutput(io.Discard)
+	loadTestConfig(t, "http://rancher.invalid")
+
+	const namespace = "p-abcde-default"
+	clientSet := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   namespace,
+			Labels: map[string]string{projectIdLabel: "p-abcde", planLabel: PlanTrial},
+		}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: namespace},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: namespace},
+			Spec:       appsv1.StatefulSetSpec{Replicas: int32Ptr(2)},
+		},
+	)
+	cluster.SetClientSet("c-abcde", clientSet)
+	ctx := context.Background()
+
+	// a second suspension must not overwrite the recorded replica counts
+	for i := 0; i < 2; i++ {
+		_, err := SuspendProject(ctx, "c-abcde:p-abcde")
+		if err != nil {
+			t.Fatalf("SuspendProject() failed: %v", err)
+		}
+	}
+	assertReplicas(t, clientSet, namespace, map[string]int32{"web": 0, "worker": 0, "db": 0})
+	_, err := clientSet.CoreV1().ResourceQuotas(namespace).Get(ctx, suspendedQuotaName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("the suspended project has no zero quota: %v", err)
+	}
+	assertNamespaceState(t, clientSet, namespace, ProjectStateSuspended)
+
+	_, err = ReactivateProject(ctx, "c-abcde:p-abcde")
+	if err != nil {
+		t.Fatalf("ReactivateProject() failed: %v", err)
+	}
+	assertReplicas(t, clientSet, namespace, map[string]int32{"web": 3, "worker": 1, "db": 2})
+	_, err = clientSet.CoreV1().ResourceQuotas(namespace).Get(ctx, suspendedQuotaName, metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("the reactivated project kept its zero quota: %v", err)
+	}
+	assertNamespaceState(t, clientSet, namespace, ProjectStateActive)
+}
+
+func assertReplicas(t *testing.T, clientSet *fake.Clientset, namespace string, want map[string]int32) {
+	t.Helper()
+	ctx := context.Background()
+	got := map[string]int32{}
+	deployments, err := clientSet.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("cannot list the deployments: %v", err)
+	}
+	for _, d := range deployments.Items {
+		got[d.Name] = replicasOrDefault(d.Spec.Replicas)
+	}
+	statefulSets, err := clientSet.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("cannot list the statefulsets: %v", err)
+	}
+	for _, s := range statefulSets.Items {
+		got[s.Name] = replicasOrDefault(s.Spec.Replicas)
+	}
+	for name, replicas := range want {
+		if got[name] != replicas {
+			t.Errorf("%s has %d replicas, want %d", name, got[name], replicas)
+		}
+	}
+}
+
+func assertNamespaceState(t *testing.T, clientSet *fake.Clientset, namespace string, want string) {
+	t.Helper()
+	ns, err := clientSet.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot read the namespace: %v", err)
+	}
+	if ns.Annotations[stateAnnotation] != want {
+		t.Fatalf("namespace state is %q, want %q", ns.Annotations[stateAnnotation], want)
+	}
+}
@@ -0,0 +1,328 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	defaultTrialDuration      = 14 * 24 * time.Hour
+	defaultTrialWarningPeriod = 3 * 24 * time.Hour
+	defaultTrialRetention     = 14 * 24 * time.Hour
+	defaultTrialSweepInterval = time.Hour
+)
+
+// Exportable functions
+
+// StartTrialSweeper checks the trial projects periodically: it warns the
+// owners of trials about to expire, suspends lapsed trials and deletes them
+// once the retention period is over. It blocks until ctx is done, a running
+// sweep is finished first, and should be run in its own goroutine.
+func StartTrialSweeper(ctx context.Context) {
+	interval := config.Get().Duration("TRIAL_SWEEP_INTERVAL", defaultTrialSweepInterval)
+	for {
+		// the shutdown waits for the running sweep, it is not cancelled with ctx
+		err := sweepTrialProjects(context.Background(), time.Now())
+		if err != nil {
+			logger.Error("trial sweeper: sweep failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ConvertTrialProject moves a trial project to a paid plan once the payment
+// is confirmed, which stops the sweeper from touching it.
+func ConvertTrialProject(ctx context.Context, projectId string, req ReqDataConvertTrial) (RespDataProjectState, error) {
+	if req.Plan == PlanTrial || genResourceQuotaFromPlan(req.Plan) == "nil" {
+		return RespDataProjectState{}, apperror.New(apperror.CodeValidationFailed, "invalid plan")
+	}
+
+	pr, err := getRancherProject(ctx, projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+	if pr.Annotations[planAnnotation] != PlanTrial {
+		return RespDataProjectState{}, apperror.New(apperror.CodeConflict, "project is not on the trial plan")
+	}
+
+	_, err = checkPayment(ctx, req.PaymentToken)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+
+	err = updateRancherProjectPlan(ctx, pr, req.Plan)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return RespDataProjectState{}, err
+	}
+	nsClient := clientSet.CoreV1().Namespaces()
+	for _, ns := range namespaces {
+		ns.Labels[planLabel] = req.Plan
+		delete(ns.Annotations, trialExpiresAtAnnotation)
+		delete(ns.Annotations, trialWarnedAtAnnotation)
+		_, err = nsClient.Update(ctx, &ns, metav1.UpdateOptions{})
+		if err != nil {
+			return RespDataProjectState{}, err
+		}
+	}
+
+	notifyBilling(ctx, projectId, ReqDataUpdateBillingProject{State: ProjectStateActive, Plan: req.Plan})
+
+	// a trial that already lapsed was suspended by the sweeper
+	if namespaces[0].Annotations[stateAnnotation] == ProjectStateSuspended {
+		return ReactivateProject(ctx, projectId)
+	}
+
+	return RespDataProjectState{
+		ProjectId:  projectId,
+		State:      ProjectStateActive,
+		Namespaces: namespaceNames(namespaces),
+	}, nil
+}
+
+// Local functions
+
+// checkTrialEligibility looks for the trial marker on the Rancher user, it is
+// kept there because trial projects are deleted once they lapse
+func checkTrialEligibility(ctx context.Context, userId string) error {
+	user, err := getRancherUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Annotations[trialUsedAnnotation] != "" {
+		return apperror.New(apperror.CodeConflict, "user already used the trial plan")
+	}
+	return nil
+}
+
+// reserveTrial creates the trial reservation of userId. The creation is
+// atomic, it fails when another provisioning reserved the trial first. The
+// reservation is kept once the trial is used.
+func reserveTrial(ctx context.Context, userId string) error {
+	_, err := trialReservations().Create(ctx, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: trialReservationName(userId),
+		},
+		Data: map[string]string{
+			"userId": userId,
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return apperror.New(apperror.CodeConflict, "user already used the trial plan")
+	}
+	return err
+}
+
+// releaseTrial deletes the trial reservation of userId after a failed
+// provisioning
+func releaseTrial(ctx context.Context, userId string) error {
+	err := trialReservations().Delete(ctx, trialReservationName(userId), metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// trialReservations returns the config maps of the namespace holding the
+// trial reservations
+func trialReservations() corev1.ConfigMapInterface {
+	namespace := config.Get().TrialsNamespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return auth.MyClientSet.CoreV1().ConfigMaps(namespace)
+}
+
+func trialReservationName(userId string) string {
+	return trialReservationPrefix + strings.ToLower(userId)
+}
+
+func markTrialUsed(ctx context.Context, userId string, projectId string) error {
+	user, err := getRancherUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	annotations := user.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[trialUsedAnnotation] = projectId
+	return doRancherRequest(ctx, "PUT", fmt.Sprintf("/v3/users/%s", userId), map[string]interface{}{
+		"annotations": annotations,
+	}, nil)
+}
+
+func trialExpiry(now time.Time) time.Time {
+	return now.Add(config.Get().Duration("TRIAL_DURATION", defaultTrialDuration))
+}
+
+func sweepTrialProjects(ctx context.Context, now time.Time) error {
+	warningPeriod := config.Get().Duration("TRIAL_WARNING_PERIOD", defaultTrialWarningPeriod)
+	retention := config.Get().Duration("TRIAL_RETENTION", defaultTrialRetention)
+
+	namespaces, err := listAllNamespaces(ctx, fmt.Sprintf("%s=%s", planLabel, PlanTrial))
+	if err != nil {
+		return err
+	}
+
+	// namespaces of the same project share the expiry, handle each project once
+	done := map[string]bool{}
+	for _, ns := range namespaces {
+		projectId := ns.Annotations[projectIdLabel]
+		if projectId == "" || done[projectId] {
+			continue
+		}
+		done[projectId] = true
+
+		expiresAt, err := time.Parse(time.RFC3339, ns.Annotations[trialExpiresAtAnnotation])
+		if err != nil {
+			logger.FromContext(ctx).Warn("trial sweeper: namespace without a valid expiry", "namespace", ns.Name, "error", err)
+			continue
+		}
+
+		switch {
+		case now.After(expiresAt.Add(retention)):
+			logger.FromContext(ctx).Info("trial sweeper: deleting an expired trial project", "projectId", projectId)
+			err = deleteTrialProject(ctx, projectId)
+		case now.After(expiresAt):
+			if ns.Annotations[stateAnnotation] == ProjectStateSuspended {
+				continue
+			}
+			logger.FromContext(ctx).Info("trial sweeper: suspending an expired trial project", "projectId", projectId)
+			_, err = SuspendProject(ctx, projectId)
+		case now.After(expiresAt.Add(-warningPeriod)):
+			if ns.Annotations[trialWarnedAtAnnotation] != "" {
+				continue
+			}
+			logger.FromContext(ctx).Info("trial sweeper: trial project about to expire", "projectId", projectId, "expiresAt", expiresAt.Format(time.RFC3339))
+			err = warnTrialExpiry(ctx, projectId, expiresAt, now)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("trial sweeper: cannot sweep a project", "projectId", projectId, "error", err)
+		}
+	}
+	return nil
+}
+
+// warnTrialExpiry records a warning event in every namespace of the project so
+// it shows up in the dashboard, and marks the namespaces as warned
+func warnTrialExpiry(ctx context.Context, projectId string, expiresAt time.Time, now time.Time) error {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return err
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		event := &v1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "trial-expiry-",
+				Namespace:    ns.Name,
+			},
+			InvolvedObject: v1.ObjectReference{
+				Kind:       "Namespace",
+				Name:       ns.Name,
+				APIVersion: "v1",
+			},
+			Type:           v1.EventTypeWarning,
+			Reason:         "TrialExpiring",
+			Message:        fmt.Sprintf("The trial of this project expires at %s, upgrade to a paid plan to keep it running", expiresAt.Format(time.RFC3339)),
+			FirstTimestamp: metav1.NewTime(now),
+			LastTimestamp:  metav1.NewTime(now),
+			Count:          1,
+			Source: v1.EventSource{
+				Component: "go-provisioner",
+			},
+		}
+		_, err = clientSet.CoreV1().Events(ns.Name).Create(ctx, event, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+
+		ns.Annotations[trialWarnedAtAnnotation] = now.Format(time.RFC3339)
+		_, err = clientSet.CoreV1().Namespaces().Update(ctx, &ns, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTrialProject deletes the billing record, then the Rancher project,
+// which deletes its namespaces. The sweep finds the trials by their
+// namespaces, so they are only left once both deletions succeeded and the
+// next sweep retries a failed one.
+func deleteTrialProject(ctx context.Context, projectId string) error {
+	clientSet, err := clientFor(projectId)
+	if err != nil {
+		return err
+	}
+	if config.Get().BillingProjectPath == "" {
+		logger.FromContext(ctx).Warn("trial sweeper: BILLING_PROJECT_PATH is not set, keeping the billing record", "projectId", projectId)
+	} else {
+		err = deleteBillingProject(ctx, projectId)
+		if err != nil {
+			return err
+		}
+	}
+	namespaces, err := listProjectNamespaces(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	// a project already deleted by a previous sweep only has namespaces left
+	err = deleteRancherProject(ctx, projectId)
+	if err != nil && apperror.From(err).Code != apperror.CodeNotFound {
+		return err
+	}
+	for _, ns := range namespaces {
+		err = clientSet.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func updateRancherProjectPlan(ctx context.Context, pr RancherProject, plan string) error {
+	resourceQuota := genResourceQuotaFromPlan(plan)
+
+	annotations := pr.Annotations
+	annotations[planAnnotation] = plan
+	if plan != PlanTrial {
+		delete(annotations, trialExpiresAtAnnotation)
+	}
+
+	annotationsJson, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+
+	body := json.RawMessage(fmt.Sprintf(`{"annotations":%s,%s}`, annotationsJson, resourceQuota))
+	return doRancherRequest(ctx, "PUT", fmt.Sprintf("/v3/projects/%s", pr.Id), body, nil)
+}
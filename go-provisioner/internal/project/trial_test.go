@@ -0,0 +1,137 @@
+package project
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSweepTrialProjects(t *testing.T) {
+	logger.SetOutput(io.Discard)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		expiresAt   string
+		annotations map[string]string
+		want        string
+	}{
+		{name: "running", expiresAt: now.Add(10 * 24 * time.Hour).Format(time.RFC3339), want: "nothing"},
+		{name: "in the warning period", expiresAt: now.Add(24 * time.Hour).Format(time.RFC3339), want: "warned"},
+		{
+			name:        "already warned",
+			expiresAt:   now.Add(24 * time.Hour).Format(time.RFC3339),
+			annotations: map[string]string{trialWarnedAtAnnotation: now.Add(-time.Hour).Format(time.RFC3339)},
+			want:        "nothing",
+		},
+		{name: "expired", expiresAt: now.Add(-24 * time.Hour).Format(time.RFC3339), want: "suspended"},
+		{
+			name:        "already suspended",
+			expiresAt:   now.Add(-24 * time.Hour).Format(time.RFC3339),
+			annotations: map[string]string{stateAnnotation: ProjectStateSuspended},
+			want:        "nothing",
+		},
+		{name: "past the retention", expiresAt: now.Add(-8 * 24 * time.Hour).Format(time.RFC3339), want: "deleted"},
+		{name: "invalid expiry", expiresAt: "soon", want: "nothing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu := sync.Mutex{}
+			rancherDeleted := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "DELETE" && r.URL.Path == "/v3/projects/c-abcde:p-trial" {
+					mu.Lock()
+					rancherDeleted = true
+					mu.Unlock()
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				t.Errorf("unexpected Rancher request %s %s", r.Method, r.URL)
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer srv.Close()
+			t.Setenv("TRIAL_WARNING_PERIOD", "72h")
+			t.Setenv("TRIAL_RETENTION", "168h")
+			loadTestConfig(t, srv.URL)
+
+			annotations := map[string]string{
+				projectIdLabel:           "c-abcde:p-trial",
+				trialExpiresAtAnnotation: tt.expiresAt,
+			}
+			for k, v := range tt.annotations {
+				annotations[k] = v
+			}
+			clientSet := fake.NewSimpleClientset(&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "p-trial-default",
+					Labels:      map[string]string{projectIdLabel: "p-trial", planLabel: PlanTrial},
+					Annotations: annotations,
+				},
+			})
+			cluster.SetClientSet("c-abcde", clientSet)
+
+			err := sweepTrialProjects(context.Background(), now)
+			if err != nil {
+				t.Fatalf("sweepTrialProjects() failed: %v", err)
+			}
+
+			if got := sweepOutcome(t, clientSet, "p-trial-default", now); got != tt.want {
+				t.Fatalf("the sweep did %s, want %s", got, tt.want)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if rancherDeleted != (tt.want == "deleted") {
+				t.Fatalf("the Rancher project deletion is %v, want %v", rancherDeleted, tt.want == "deleted")
+			}
+		})
+	}
+}
+
+// sweepOutcome tells what the trial sweep did to the namespace name, checking
+// that each action is recorded on it
+func sweepOutcome(t *testing.T, clientSet kubernetes.Interface, name string, now time.Time) string {
+	t.Helper()
+	ctx := context.Background()
+	ns, err := clientSet.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "deleted"
+	}
+	if err != nil {
+		t.Fatalf("cannot read the namespace: %v", err)
+	}
+
+	events, err := clientSet.CoreV1().Events(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("cannot list the events: %v", err)
+	}
+	_, err = clientSet.CoreV1().ResourceQuotas(name).Get(ctx, suspendedQuotaName, metav1.GetOptions{})
+	suspended := err == nil
+
+	switch {
+	case len(events.Items) > 0 && suspended:
+		return "warned and suspended"
+	case len(events.Items) > 0:
+		if ns.Annotations[trialWarnedAtAnnotation] != now.Format(time.RFC3339) {
+			t.Errorf("warned without recording it on the namespace: %v", ns.Annotations)
+		}
+		return "warned"
+	case suspended:
+		if ns.Annotations[stateAnnotation] != ProjectStateSuspended {
+			t.Errorf("suspended without recording it on the namespace: %v", ns.Annotations)
+		}
+		return "suspended"
+	}
+	return "nothing"
+}
@@ -4,9 +4,39 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Creometry/dashboard/go-provisioner/internal/catalog"
+	"github.com/Creometry/dashboard/go-provisioner/internal/placement"
 	"github.com/google/uuid"
 )
 
+const (
+	ProjectStateActive    = "active"
+	ProjectStateSuspended = "suspended"
+)
+
+// PlanTrial is the free plan, it needs no payment and expires after TRIAL_DURATION
+const PlanTrial = "Trial"
+
+const (
+	projectIdLabel             = "field.cattle.io/projectId"
+	planLabel                  = "creometry.com/plan"
+	planAnnotation             = "creometry.com/plan"
+	ownerAnnotation            = "creometry.com/owner"
+	stateAnnotation            = "creometry.com/state"
+	trialUsedAnnotation        = "creometry.com/trial-used"
+	trialReservationPrefix     = "trial-"
+	trialExpiresAtAnnotation   = "creometry.com/trial-expires-at"
+	trialWarnedAtAnnotation    = "creometry.com/trial-warned-at"
+	previousReplicasAnnotation = "creometry.com/previous-replicas"
+	suspendedQuotaName         = "suspended-quota"
+	managedByAnnotation        = "creometry.com/provisioned-by"
+	provisionedAtAnnotation    = "creometry.com/provisioned-at"
+)
+
+// managedBy marks the Rancher projects created by this service, only those are
+// reconciled
+const managedBy = "go-provisioner"
+
 type ReqData struct {
 	// TODO: add billing account data and validte it
 	UsrProjectName   string `json:"projectName"`
@@ -23,6 +53,12 @@ type ReqData struct {
 	TaxId            string `json:"taxId"`
 	Phone            string `json:"phone"`
 	Email            string `json:"email"`
+	// Template is the id of a catalog template applied to the new namespace
+	Template       string            `json:"template"`
+	TemplateParams map[string]string `json:"templateParams"`
+	// Region restricts the clusters the project can be placed on, any
+	// cluster can be chosen when it is empty
+	Region string `json:"region"`
 }
 
 type ReqDataNewUser struct {
@@ -70,7 +106,7 @@ func (r *ReqData) Validate() error {
 	if r.BillingAccountId == "" {
 		return fmt.Errorf("billing account id is required")
 	}
-	if r.PaymentToken == "" {
+	if r.PaymentToken == "" && r.Plan != PlanTrial {
 		return fmt.Errorf("payment token is required")
 	}
 	if r.UsrProjectName == "" {
@@ -88,9 +124,17 @@ func (r *ReqData) Validate() error {
 	if r.BillingAccountId == "1" && r.Email == "" {
 		return fmt.Errorf("email is required")
 	}
+	if r.BillingAccountId != "1" {
+		if _, err := uuid.Parse(r.BillingAccountId); err != nil {
+			return fmt.Errorf("invalid billing account id %s: %v", r.BillingAccountId, err)
+		}
+	}
 	if r.IsCompany && (r.CompanyName == "" || r.TaxId == "") {
 		return fmt.Errorf("company name and tax id are required")
 	}
+	if r.Template != "" {
+		return catalog.Validate(r.Template, r.TemplateParams)
+	}
 	return nil
 }
 
@@ -111,7 +155,10 @@ type RespData struct {
 }
 
 type RespDataProvisionProject struct {
-	ProjectId string `json:"projectId"`
+	ProjectId string                 `json:"projectId"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Template  []catalog.ObjectResult `json:"template,omitempty"`
+	Placement *placement.Decision    `json:"placement,omitempty"`
 }
 
 type RespDataProvisionProjectNewUser struct {
@@ -153,6 +200,8 @@ type RespDataCreateGitRepo struct {
 
 type ReqDataKubeconfig struct {
 	Token string `json:"token"`
+	// ClusterId is the Rancher id of the cluster, the default cluster when empty
+	ClusterId string `json:"clusterId"`
 }
 
 type RespDataUser struct {
@@ -254,3 +303,204 @@ type ReqDataAddProjectToBillingAccount struct {
 	Plan               string    `json:"plan"`
 	State              string    `json:"state"`
 }
+
+type RespDataProjectState struct {
+	ProjectId  string   `json:"projectId"`
+	State      string   `json:"state"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// ReqDataUpdateBillingProject reports a change of a project to the billing
+// service, the empty fields are left unchanged
+type ReqDataUpdateBillingProject struct {
+	State string `json:"state,omitempty"`
+	Plan  string `json:"plan,omitempty"`
+}
+
+type ReqDataConvertTrial struct {
+	Plan         string `json:"plan"`
+	PaymentToken string `json:"paymentToken"`
+}
+
+func (r *ReqDataConvertTrial) Validate() error {
+	if r.Plan == "" {
+		return fmt.Errorf("plan is required")
+	}
+	if r.PaymentToken == "" {
+		return fmt.Errorf("payment token is required")
+	}
+	return nil
+}
+
+type RespDataRancherError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type RespDataRancherProjects struct {
+	Data       []RancherProject  `json:"data"`
+	Pagination RancherPagination `json:"pagination"`
+}
+
+// RancherPagination is set on the collections longer than one page, next is
+// the absolute url of the following page
+type RancherPagination struct {
+	Next    string `json:"next"`
+	Partial bool   `json:"partial"`
+}
+
+type RancherProject struct {
+	Id            string               `json:"id"`
+	Name          string               `json:"name"`
+	ClusterId     string               `json:"clusterId"`
+	State         string               `json:"state"`
+	CreatedTS     int64                `json:"createdTS"`
+	Annotations   map[string]string    `json:"annotations"`
+	Labels        map[string]string    `json:"labels"`
+	ResourceQuota RancherResourceQuota `json:"resourceQuota"`
+}
+
+type RancherResourceQuota struct {
+	Limit     map[string]string `json:"limit"`
+	UsedLimit map[string]string `json:"usedLimit"`
+}
+
+type RancherUser struct {
+	Id          string            `json:"id"`
+	Username    string            `json:"username"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type RespDataProjectQuota struct {
+	ProjectId  string           `json:"projectId"`
+	Project    []QuotaUsage     `json:"project"`
+	Namespaces []NamespaceQuota `json:"namespaces"`
+}
+
+type NamespaceQuota struct {
+	Namespace string       `json:"namespace"`
+	Resources []QuotaUsage `json:"resources"`
+}
+
+type QuotaUsage struct {
+	Resource  string  `json:"resource"`
+	Hard      string  `json:"hard"`
+	Used      string  `json:"used"`
+	Percent   float64 `json:"percent"`
+	NearLimit bool    `json:"nearLimit"`
+}
+
+type ReqDataNamespace struct {
+	Name string `json:"name"`
+}
+
+type RespDataRancherUsers struct {
+	Data []RancherUser `json:"data"`
+}
+
+type RespDataProjectRoleBindings struct {
+	Data []ProjectRoleBinding `json:"data"`
+}
+
+type ProjectRoleBinding struct {
+	Id             string `json:"id"`
+	UserId         string `json:"userId"`
+	ProjectId      string `json:"projectId"`
+	RoleTemplateId string `json:"roleTemplateId"`
+}
+
+type ReqDataProjectKubeconfig struct {
+	Token             string `json:"token"`
+	ExpirationSeconds int64  `json:"expirationSeconds"`
+}
+
+type RespDataProjectKubeconfig struct {
+	Config    string    `json:"config"`
+	Namespace string    `json:"namespace"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ReqDataRegistry struct {
+	Name     string `json:"name"`
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+func (r *ReqDataRegistry) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Server == "" {
+		return fmt.Errorf("server is required")
+	}
+	if r.Username == "" || r.Password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+	return nil
+}
+
+type Registry struct {
+	Name      string `json:"name"`
+	Server    string `json:"server"`
+	Username  string `json:"username"`
+	RotatedAt string `json:"rotatedAt"`
+}
+
+type PlanQuota struct {
+	NamespaceDefaultResourceQuota RancherResourceQuota `json:"namespaceDefaultResourceQuota"`
+	ResourceQuota                 RancherResourceQuota `json:"resourceQuota"`
+}
+
+type ReqDataGC struct {
+	DryRun bool   `json:"dryRun"`
+	MinAge string `json:"minAge"`
+	// MaxDeletions overrides GC_MAX_DELETIONS when set
+	MaxDeletions int `json:"maxDeletions"`
+}
+
+func (r *ReqDataGC) Validate() error {
+	if r.MaxDeletions < 0 {
+		return fmt.Errorf("maxDeletions must be positive")
+	}
+	if r.MinAge == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(r.MinAge)
+	if err != nil {
+		return fmt.Errorf("invalid minAge: %w", err)
+	}
+	if d < 0 {
+		return fmt.Errorf("minAge must be positive")
+	}
+	return nil
+}
+
+type RespDataGC struct {
+	DryRun       bool     `json:"dryRun"`
+	MinAge       string   `json:"minAge"`
+	MaxDeletions int      `json:"maxDeletions"`
+	Orphans      []Orphan `json:"orphans"`
+}
+
+// RespDataBillingError is the error body of the billing service
+type RespDataBillingError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+type Orphan struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	ClusterId string `json:"clusterId"`
+	ProjectId string `json:"projectId"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"createdAt"`
+	// Expired is true when the orphan is older than minAge, only those are deleted
+	Expired bool   `json:"expired"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
@@ -0,0 +1,41 @@
+package project
+
+import "testing"
+
+func TestReqDataValidateBillingAccountId(t *testing.T) {
+	tests := []struct {
+		name             string
+		billingAccountId string
+		wantErr          bool
+	}{
+		{name: "new account", billingAccountId: "1"},
+		{name: "uuid", billingAccountId: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{name: "upper case uuid", billingAccountId: "6BA7B810-9DAD-11D1-80B4-00C04FD430C8"},
+		{name: "urn uuid", billingAccountId: "urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{name: "empty", billingAccountId: "", wantErr: true},
+		{name: "not a uuid", billingAccountId: "billing-account", wantErr: true},
+		{name: "truncated uuid", billingAccountId: "6ba7b810-9dad-11d1-80b4", wantErr: true},
+		{name: "invalid character", billingAccountId: "6ba7b810-9dad-11d1-80b4-00c04fd430cz", wantErr: true},
+		{name: "other number", billingAccountId: "2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ReqData{
+				BillingAccountId: tt.billingAccountId,
+				PaymentToken:     "payment-token",
+				UsrProjectName:   "project",
+				Plan:             "Starter",
+				UserId:           "u-abcde",
+				UUID:             "user-uuid",
+				Email:            "owner@example.com",
+			}
+			err := req.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatalf("Validate() accepted billing account id %q", tt.billingAccountId)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Validate() rejected billing account id %q: %v", tt.billingAccountId, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// how often the memory store drops the keys it no longer needs
+const sweepInterval = time.Minute
+
+type entry struct {
+	failures    []time.Time
+	window      time.Duration
+	lockedUntil time.Time
+	level       int
+}
+
+// MemoryStore is a Store local to the process
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*entry{}}
+}
+
+func (s *MemoryStore) AddFailure(key string, at time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(at)
+	e, ok := s.entries[key]
+	if !ok {
+		e = &entry{}
+		s.entries[key] = e
+	}
+	e.window = window
+	e.failures = append(prune(e.failures, at.Add(-window)), at)
+	return len(e.failures), nil
+}
+
+func (s *MemoryStore) Lockout(key string) (time.Time, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return time.Time{}, 0, nil
+	}
+	return e.lockedUntil, e.level, nil
+}
+
+func (s *MemoryStore) SetLockout(key string, until time.Time, level int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &entry{}
+		s.entries[key] = e
+	}
+	e.lockedUntil = until
+	e.level = level
+	// the failures that led to the lockout do not count towards the next one
+	e.failures = nil
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops the keys that are neither locked out nor have recent failures.
+// The lockout level of a key is forgotten once it has been quiet for a whole
+// window after its last lockout.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		e.failures = prune(e.failures, now.Add(-e.window))
+		if len(e.failures) == 0 && now.After(e.lockedUntil.Add(e.window)) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// prune drops the attempts before since, the attempts are in order
+func prune(attempts []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(attempts) && !attempts[i].After(since) {
+		i++
+	}
+	return attempts[i:]
+}
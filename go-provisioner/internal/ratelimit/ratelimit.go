@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+)
+
+const (
+	defaultMaxFailures      = 5
+	defaultMaxFailuresPerIP = 20
+	defaultWindow           = 15 * time.Minute
+	defaultLockout          = time.Minute
+	defaultMaxLockout       = time.Hour
+
+	// the usernames tried from many ips get a short backoff rather than a
+	// long lockout, anybody can trigger it for any account
+	defaultMaxFailuresPerUser = 50
+	defaultUserMaxLockout     = 5 * time.Minute
+)
+
+var (
+	storeMu sync.RWMutex
+	store   Store = NewMemoryStore()
+)
+
+// Exportable functions
+
+// SetStore replaces the in-memory store, e.g. with one shared by the replicas
+func SetStore(s Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	store = s
+}
+
+// LoginPolicies returns the policies of the login endpoint per username and
+// client ip, per username and per client ip, read from the LOGIN_* variables
+func LoginPolicies() (Policy, Policy, Policy) {
+	base := Policy{
+		Window:     config.Get().Duration("LOGIN_WINDOW", defaultWindow),
+		Lockout:    config.Get().Duration("LOGIN_LOCKOUT", defaultLockout),
+		MaxLockout: config.Get().Duration("LOGIN_MAX_LOCKOUT", defaultMaxLockout),
+	}
+	userIP, user, ip := base, base, base
+	userIP.MaxFailures = config.Get().Int("LOGIN_MAX_ATTEMPTS", defaultMaxFailures)
+	user.MaxFailures = config.Get().Int("LOGIN_MAX_ATTEMPTS_PER_USER", defaultMaxFailuresPerUser)
+	user.MaxLockout = config.Get().Duration("LOGIN_USER_MAX_LOCKOUT", defaultUserMaxLockout)
+	if user.Lockout > user.MaxLockout {
+		user.Lockout = user.MaxLockout
+	}
+	ip.MaxFailures = config.Get().Int("LOGIN_MAX_ATTEMPTS_PER_IP", defaultMaxFailuresPerIP)
+	return userIP, user, ip
+}
+
+// RetryAfter returns how long key is still locked out, zero when it may try.
+// A failing store lets the attempt through rather than locking everybody out.
+func RetryAfter(key string, now time.Time) time.Duration {
+	until, _, err := getStore().Lockout(key)
+	if err != nil {
+		logger.Error("rate limit: cannot read the lockout", "key", key, "error", err)
+		return 0
+	}
+	if until.After(now) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// Fail records a failed attempt on key, and locks it out once it has reached
+// the failures allowed by p. It returns the lockout duration, if any.
+func Fail(key string, p Policy, now time.Time) time.Duration {
+	s := getStore()
+	failures, err := s.AddFailure(key, now, p.Window)
+	if err != nil {
+		logger.Error("rate limit: cannot record a failure", "key", key, "error", err)
+		return 0
+	}
+	if failures < p.MaxFailures {
+		return 0
+	}
+
+	_, level, err := s.Lockout(key)
+	if err != nil {
+		logger.Error("rate limit: cannot read the lockout", "key", key, "error", err)
+		return 0
+	}
+	lockout := p.Lockout
+	for i := 0; i < level && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	if err := s.SetLockout(key, now.Add(lockout), level+1); err != nil {
+		logger.Error("rate limit: cannot lock out", "key", key, "error", err)
+		return 0
+	}
+	return lockout
+}
+
+// Succeed forgets the failures and the lockouts of key
+func Succeed(key string) {
+	if err := getStore().Reset(key); err != nil {
+		logger.Error("rate limit: cannot reset", "key", key, "error", err)
+	}
+}
+
+// Local functions
+
+func getStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return store
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFail(t *testing.T) {
+	p := Policy{
+		MaxFailures: 3,
+		Window:      time.Minute,
+		Lockout:     time.Minute,
+		MaxLockout:  3 * time.Minute,
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		failures    int
+		interval    time.Duration
+		wantLockout time.Duration
+	}{
+		{name: "below the limit", failures: 2, interval: time.Second},
+		{name: "at the limit", failures: 3, interval: time.Second, wantLockout: time.Minute},
+		{name: "second lockout doubles", failures: 6, interval: time.Second, wantLockout: 2 * time.Minute},
+		{name: "lockout is capped", failures: 9, interval: time.Second, wantLockout: 3 * time.Minute},
+		{name: "failures outside the window", failures: 6, interval: 40 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStore(NewMemoryStore())
+			at := now
+			lockout := time.Duration(0)
+			for i := 0; i < tt.failures; i++ {
+				at = now.Add(time.Duration(i) * tt.interval)
+				lockout = Fail("login:ip:10.0.0.1", p, at)
+			}
+			if lockout != tt.wantLockout {
+				t.Fatalf("Fail() locked out for %v, want %v", lockout, tt.wantLockout)
+			}
+			if d := RetryAfter("login:ip:10.0.0.1", at); d != tt.wantLockout {
+				t.Fatalf("RetryAfter() = %v, want %v", d, tt.wantLockout)
+			}
+			if d := RetryAfter("login:ip:10.0.0.2", at); d != 0 {
+				t.Fatalf("RetryAfter() of another key = %v, want 0", d)
+			}
+		})
+	}
+}
+
+func TestSucceedResets(t *testing.T) {
+	SetStore(NewMemoryStore())
+	p := Policy{MaxFailures: 2, Window: time.Minute, Lockout: time.Minute, MaxLockout: time.Hour}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	key := "login:user:alice"
+
+	Fail(key, p, now)
+	Fail(key, p, now)
+	Fail(key, p, now)
+	if d := Fail(key, p, now); d != 2*time.Minute {
+		t.Fatalf("second lockout is %v, want %v", d, 2*time.Minute)
+	}
+
+	Succeed(key)
+	if d := RetryAfter(key, now); d != 0 {
+		t.Fatalf("RetryAfter() after a success = %v, want 0", d)
+	}
+	if d := Fail(key, p, now); d != 0 {
+		t.Fatalf("first failure after a success locked out for %v", d)
+	}
+	if d := Fail(key, p, now); d != time.Minute {
+		t.Fatalf("lockout after a success is %v, want the first level %v", d, time.Minute)
+	}
+}
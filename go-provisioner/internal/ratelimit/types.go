@@ -0,0 +1,28 @@
+package ratelimit
+
+import "time"
+
+// Store keeps the failed attempts and the lockouts of the limited keys. The
+// default store is in memory, replicas share a store by setting the same
+// external one with SetStore.
+type Store interface {
+	// AddFailure records a failed attempt on key and returns the number of
+	// failures within window, the new one included
+	AddFailure(key string, at time.Time, window time.Duration) (int, error)
+	// Lockout returns the end of the current lockout of key, the zero time
+	// when it is not locked, and the number of lockouts since the last reset
+	Lockout(key string) (time.Time, int, error)
+	// SetLockout locks key until the given time
+	SetLockout(key string, until time.Time, level int) error
+	// Reset forgets the failures and the lockouts of key
+	Reset(key string) error
+}
+
+// Policy is the number of failures a key may do within Window before being
+// locked out. Each consecutive lockout doubles, up to MaxLockout.
+type Policy struct {
+	MaxFailures int
+	Window      time.Duration
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+}
@@ -1,18 +1,21 @@
 package team
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
-	"github.com/Creometry/dashboard/go-provisioner/utils"
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
 )
 
 // Exportable function
 
-func ListTeamMembers(projectId string) ([]RespDataUserByUserId, error) {
+func ListTeamMembers(ctx context.Context, projectId string) ([]RespDataUserByUserId, error) {
 
 	rancherToken, rancherURL, err := getRancherTokenAndUrl()
 
@@ -20,7 +23,7 @@ func ListTeamMembers(projectId string) ([]RespDataUserByUserId, error) {
 		return []RespDataUserByUserId{}, err
 	}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/projectroletemplatebindings?projectId=", projectId), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/projectroletemplatebindings?projectId=", projectId), nil)
 
 	if err != nil {
 		return nil, err
@@ -28,32 +31,41 @@ func ListTeamMembers(projectId string) ([]RespDataUserByUserId, error) {
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return nil, err
+		return nil, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 300 {
+		return nil, apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("listing the members of project %s failed with status %d", projectId, resp.StatusCode))
+	}
+
 	dt := RespDataTeamMembers{}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
-		return nil, err
+		return nil, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 	var res []RespDataUserByUserId
 	if len(dt.Data) > 0 {
 		// loop through all the members, get their userId and get their names
 		for _, user := range dt.Data {
-			d, err := getUserById(strings.Split(user.UserId, "/")[0])
+			d, err := getUserById(ctx, strings.Split(user.UserId, "/")[0])
 			if err != nil {
-				continue
+				// the bindings of deleted users are skipped, any other failure
+				// would return a partial list
+				if apperror.From(err).Code == apperror.CodeNotFound {
+					continue
+				}
+				return nil, err
 			} else {
 				if d.Type != "error" {
 					res = append(res, d)
@@ -67,7 +79,7 @@ func ListTeamMembers(projectId string) ([]RespDataUserByUserId, error) {
 
 // Local functions
 
-func getUserById(userId string) (RespDataUserByUserId, error) {
+func getUserById(ctx context.Context, userId string) (RespDataUserByUserId, error) {
 
 	rancherToken, rancherURL, err := getRancherTokenAndUrl()
 
@@ -75,32 +87,36 @@ func getUserById(userId string) (RespDataUserByUserId, error) {
 		return RespDataUserByUserId{}, err
 	}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/users/", userId), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s%s%s", rancherURL, "/v3/users/", userId), nil)
 	if err != nil {
 		return RespDataUserByUserId{}, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", rancherToken))
 
-	client := &http.Client{}
+	client := metrics.Client(metrics.UpstreamRancher)
 
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return RespDataUserByUserId{}, err
+		return RespDataUserByUserId{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 300 {
+		return RespDataUserByUserId{}, apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("reading user %s failed with status %d", userId, resp.StatusCode))
+	}
+
 	// parse response body
 	dt := RespDataUserByUserId{}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return RespDataUserByUserId{}, err
+		return RespDataUserByUserId{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 	err = json.Unmarshal(body, &dt)
 	if err != nil {
-		return RespDataUserByUserId{}, err
+		return RespDataUserByUserId{}, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
 	}
 
 	return dt, nil
@@ -108,15 +124,9 @@ func getUserById(userId string) (RespDataUserByUserId, error) {
 }
 
 func getRancherTokenAndUrl() (string, string, error) {
-	rancherURL, err := utils.GetVariable("config", "RANCHER_URL")
-	if err != nil {
-		return "", "", err
-	}
+	rancherURL := config.Get().RancherURL
 
-	rancherToken, err := utils.GetVariable("secrets", "RANCHER_TOKEN")
-	if err != nil {
-		return "", "", err
-	}
+	rancherToken := config.Get().RancherToken
 
 	return rancherToken, rancherURL, nil
 }
@@ -0,0 +1,205 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Prefix starts every api token so they can be told apart from Rancher tokens
+const Prefix = "crt_"
+
+const (
+	tokenLabel   = "creometry.com/api-token"
+	ownerLabel   = "creometry.com/owner"
+	secretPrefix = "api-token-"
+)
+
+var ErrInvalidToken = apperror.New(apperror.CodeUnauthorized, "invalid api token")
+
+// Exportable functions
+
+// IsApiToken tells whether raw looks like an api token
+func IsApiToken(raw string) bool {
+	return strings.HasPrefix(raw, Prefix)
+}
+
+// CreateToken generates a new token for userId. Only the sha256 of its secret
+// part is stored, the plain token is returned once to the caller.
+func CreateToken(ctx context.Context, userId string, req ReqDataCreateToken) (RespDataCreateToken, error) {
+	id, err := randomHex(6)
+	if err != nil {
+		return RespDataCreateToken{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return RespDataCreateToken{}, err
+	}
+
+	t := ApiToken{
+		Id:        id,
+		Name:      req.Name,
+		UserId:    userId,
+		Scope:     req.Scope,
+		ProjectId: req.ProjectId,
+		CreatedAt: time.Now().UTC(),
+	}
+	data := map[string]string{
+		"name":      t.Name,
+		"userId":    t.UserId,
+		"scope":     t.Scope,
+		"projectId": t.ProjectId,
+		"createdAt": t.CreatedAt.Format(time.RFC3339),
+		"hash":      hashSecret(secret),
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := t.CreatedAt.Add(time.Duration(req.ExpiresIn) * time.Second)
+		t.ExpiresAt = &expiresAt
+		data["expiresAt"] = expiresAt.Format(time.RFC3339)
+	}
+
+	_, err = auth.MyClientSet.CoreV1().Secrets(getTokensNamespace()).Create(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: secretPrefix + id,
+			Labels: map[string]string{
+				tokenLabel: "true",
+				ownerLabel: userId,
+			},
+		},
+		Type:       v1.SecretTypeOpaque,
+		StringData: data,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return RespDataCreateToken{}, err
+	}
+
+	return RespDataCreateToken{
+		Token:    fmt.Sprintf("%s%s_%s", Prefix, id, secret),
+		ApiToken: t,
+	}, nil
+}
+
+func ListTokens(ctx context.Context, userId string) ([]ApiToken, error) {
+	list, err := auth.MyClientSet.CoreV1().Secrets(getTokensNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true,%s=%s", tokenLabel, ownerLabel, userId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := []ApiToken{}
+	for _, s := range list.Items {
+		res = append(res, fromSecret(s))
+	}
+	return res, nil
+}
+
+// RevokeToken deletes a token, users can only revoke their own tokens
+func RevokeToken(ctx context.Context, userId string, id string) error {
+	client := auth.MyClientSet.CoreV1().Secrets(getTokensNamespace())
+	s, err := client.Get(ctx, secretPrefix+id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return apperror.Newf(apperror.CodeNotFound, "token %s not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	if s.Labels[tokenLabel] != "true" || string(s.Data["userId"]) != userId {
+		return apperror.Newf(apperror.CodeNotFound, "token %s not found", id)
+	}
+	return client.Delete(ctx, s.Name, metav1.DeleteOptions{})
+}
+
+// Verify checks a plain token against the stored hash and its expiry
+func Verify(ctx context.Context, raw string) (ApiToken, error) {
+	id, secret, ok := parse(raw)
+	if !ok {
+		return ApiToken{}, ErrInvalidToken
+	}
+
+	s, err := auth.MyClientSet.CoreV1().Secrets(getTokensNamespace()).Get(ctx, secretPrefix+id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return ApiToken{}, ErrInvalidToken
+	}
+	if err != nil {
+		return ApiToken{}, err
+	}
+	if s.Labels[tokenLabel] != "true" {
+		return ApiToken{}, ErrInvalidToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), s.Data["hash"]) != 1 {
+		return ApiToken{}, ErrInvalidToken
+	}
+
+	t := fromSecret(*s)
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return ApiToken{}, apperror.New(apperror.CodeUnauthorized, "api token expired")
+	}
+	return t, nil
+}
+
+// Local functions
+
+func parse(raw string) (string, string, bool) {
+	if !IsApiToken(raw) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(raw, Prefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func fromSecret(s v1.Secret) ApiToken {
+	t := ApiToken{
+		Id:        strings.TrimPrefix(s.Name, secretPrefix),
+		Name:      string(s.Data["name"]),
+		UserId:    string(s.Data["userId"]),
+		Scope:     string(s.Data["scope"]),
+		ProjectId: string(s.Data["projectId"]),
+	}
+	if createdAt, err := time.Parse(time.RFC3339, string(s.Data["createdAt"])); err == nil {
+		t.CreatedAt = createdAt
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, string(s.Data["expiresAt"])); err == nil {
+		t.ExpiresAt = &expiresAt
+	}
+	return t
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// getTokensNamespace returns the namespace holding the token secrets, the
+// namespace of the provisioner by default
+func getTokensNamespace() string {
+	namespace := config.Get().TokensNamespace
+	if namespace == "" {
+		return "default"
+	}
+	return namespace
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ScopeReadOnly  = "read-only"
+	ScopeReadWrite = "read-write"
+)
+
+// ApiToken is a stored token, the secret part is only known as a hash
+type ApiToken struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name"`
+	UserId    string     `json:"userId"`
+	Scope     string     `json:"scope"`
+	ProjectId string     `json:"projectId,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ReqDataCreateToken struct {
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	ProjectId string `json:"projectId"`
+	// lifetime of the token in seconds, 0 means it never expires
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+func (r *ReqDataCreateToken) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Scope == "" {
+		r.Scope = ScopeReadWrite
+	}
+	if r.Scope != ScopeReadOnly && r.Scope != ScopeReadWrite {
+		return fmt.Errorf("scope must be %s or %s", ScopeReadOnly, ScopeReadWrite)
+	}
+	if r.ExpiresIn < 0 {
+		return fmt.Errorf("expiresIn must be positive")
+	}
+	return nil
+}
+
+type RespDataCreateToken struct {
+	// Token is the plain token, it is only returned once
+	Token    string   `json:"token"`
+	ApiToken ApiToken `json:"apiToken"`
+}
+
+type ReqDataIntrospect struct {
+	Token string `json:"token"`
+}
+
+type RespDataIntrospect struct {
+	Active    bool   `json:"active"`
+	UserId    string `json:"userId,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ProjectId string `json:"projectId,omitempty"`
+}
@@ -0,0 +1,44 @@
+package usage
+
+import "time"
+
+// HourlyRecord is the usage of one project during one hour. Averages are
+// computed over the samples taken during the hour, so the average cpu in
+// millicores is also the number of millicore-hours consumed.
+type HourlyRecord struct {
+	ProjectId              string    `json:"projectId"`
+	ClusterId              string    `json:"clusterId"`
+	Hour                   time.Time `json:"hour"`
+	Samples                int       `json:"samples"`
+	AvgCpuRequestsMilli    int64     `json:"avgCpuRequestsMillicores"`
+	MaxCpuRequestsMilli    int64     `json:"maxCpuRequestsMillicores"`
+	AvgMemoryRequestsBytes int64     `json:"avgMemoryRequestsBytes"`
+	MaxMemoryRequestsBytes int64     `json:"maxMemoryRequestsBytes"`
+	AvgStorageBytes        int64     `json:"avgStorageBytes"`
+	MaxStorageBytes        int64     `json:"maxStorageBytes"`
+	MaxLoadBalancers       int64     `json:"maxLoadBalancers"`
+	Complete               bool      `json:"complete"`
+}
+
+type ReqDataPushUsage struct {
+	Records []HourlyRecord `json:"records"`
+}
+
+type RespDataUsage struct {
+	Records []HourlyRecord `json:"records"`
+}
+
+// sample is the usage of one project at a point in time
+type sample struct {
+	CpuRequestsMilli    int64
+	MemoryRequestsBytes int64
+	StorageBytes        int64
+	LoadBalancers       int64
+}
+
+type accumulator struct {
+	record   HourlyRecord
+	cpuSum   int64
+	memSum   int64
+	storeSum int64
+}
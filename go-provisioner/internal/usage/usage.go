@@ -0,0 +1,350 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/leader"
+	"github.com/Creometry/dashboard/go-provisioner/internal/metrics"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	projectIdLabel        = "field.cattle.io/projectId"
+	defaultSampleInterval = 5 * time.Minute
+	// only the replica holding this lease samples and pushes the usage
+	meteringLease = "go-provisioner-metering"
+	// the outbox keeps the records of a billing outage of about a day for a
+	// few hundred projects, the oldest records are dropped beyond that
+	maxOutboxRecords = 10000
+)
+
+var (
+	mu      sync.Mutex
+	current = map[string]*accumulator{}
+	// records that could not be pushed to the billing service yet. They are
+	// only kept in memory: Flush pushes them on shutdown, but they are lost,
+	// with the current hour, if the process crashes.
+	outbox []HourlyRecord
+)
+
+// Exportable functions
+
+// StartMetering samples the resource usage of every project periodically
+// and pushes the hourly aggregates to the billing service. Only the replica
+// holding the metering lease samples, the projects would be billed once per
+// replica otherwise. It blocks until ctx is done, a running sample is
+// finished first, and should be run in its own goroutine. The records left
+// in the outbox are pushed by Flush.
+func StartMetering(ctx context.Context) {
+	leader.Run(ctx, meteringLease, meter)
+}
+
+// GetUsage returns the hourly usage records of a project from the billing
+// service, which has the records pushed by every leader. The current hour is
+// only there once it is complete.
+func GetUsage(ctx context.Context, projectId string) ([]HourlyRecord, error) {
+	billingURL := config.Get().BillingURL
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/v1/usage?projectId=%s", billingURL, url.QueryEscape(projectId)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := metrics.Client(metrics.UpstreamBilling).Do(req)
+	if err != nil {
+		return nil, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, apperror.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("reading the usage of project %s failed with status %d", projectId, resp.StatusCode))
+	}
+
+	data := RespDataUsage{}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	return data.Records, nil
+}
+
+// Flush pushes the completed records that are waiting in the outbox to the
+// billing service, they stay in the outbox if the push fails
+func Flush(ctx context.Context) error {
+	mu.Lock()
+	pending := outbox
+	outbox = nil
+	mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := pushUsage(ctx, pending)
+	if err != nil {
+		mu.Lock()
+		outbox = append(pending, outbox...)
+		trimOutbox()
+		mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Local functions
+
+// meter runs the metering while this replica is the leader, until ctx is
+// cancelled. The hour in progress is dropped when the lease is lost: the new
+// leader starts its own record of that hour, averaged over its own samples.
+func meter(ctx context.Context) {
+	interval := config.Get().Duration("USAGE_SAMPLE_INTERVAL", defaultSampleInterval)
+	defer dropCurrent()
+
+	// the shutdown waits for the running sample, it is not cancelled with ctx
+	runCtx := context.Background()
+	for {
+		now := time.Now()
+		// close the previous hour before the first sample of a new one
+		closeHour(now)
+		samples, err := sampleProjects(runCtx)
+		if err != nil {
+			logger.Error("usage metering: sampling failed", "error", err)
+		} else {
+			record(now, samples)
+		}
+		err = Flush(runCtx)
+		if err != nil {
+			logger.Error("usage metering: flush failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func sampleProjects(ctx context.Context) (map[string]sample, error) {
+	clusters, err := cluster.List()
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]sample{}
+	for _, c := range clusters {
+		// a cluster that cannot be sampled does not stop the metering of the
+		// others, its projects are left out of this sample instead of being
+		// billed for the namespaces sampled before the failure
+		samples, err := sampleCluster(ctx, c.RancherClusterId)
+		if err != nil {
+			logger.FromContext(ctx).Error("usage: cannot sample a cluster", "cluster", c.Id, "error", err)
+			continue
+		}
+		for projectId, s := range samples {
+			res[projectId] = s
+		}
+	}
+	return res, nil
+}
+
+func sampleCluster(ctx context.Context, clusterId string) (map[string]sample, error) {
+	clientSet, err := cluster.ClientSet(clusterId)
+	if err != nil {
+		return nil, err
+	}
+	nsList, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: projectIdLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]sample{}
+
+	for _, ns := range nsList.Items {
+		projectId := ns.Annotations[projectIdLabel]
+		if projectId == "" {
+			continue
+		}
+		s, err := sampleNamespace(ctx, clientSet, ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		total := res[projectId]
+		total.CpuRequestsMilli += s.CpuRequestsMilli
+		total.MemoryRequestsBytes += s.MemoryRequestsBytes
+		total.StorageBytes += s.StorageBytes
+		total.LoadBalancers += s.LoadBalancers
+		res[projectId] = total
+	}
+	return res, nil
+}
+
+func sampleNamespace(ctx context.Context, clientSet kubernetes.Interface, namespace string) (sample, error) {
+	s := sample{}
+
+	pods, err := clientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return s, err
+	}
+	for _, pod := range pods.Items {
+		// finished pods do not hold their requests anymore
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			s.CpuRequestsMilli += container.Resources.Requests.Cpu().MilliValue()
+			s.MemoryRequestsBytes += container.Resources.Requests.Memory().Value()
+		}
+	}
+
+	pvcs, err := clientSet.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return s, err
+	}
+	for _, pvc := range pvcs.Items {
+		s.StorageBytes += pvc.Spec.Resources.Requests.Storage().Value()
+	}
+
+	services, err := clientSet.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return s, err
+	}
+	for _, svc := range services.Items {
+		if svc.Spec.Type == v1.ServiceTypeLoadBalancer {
+			s.LoadBalancers++
+		}
+	}
+
+	return s, nil
+}
+
+func record(now time.Time, samples map[string]sample) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	hour := now.UTC().Truncate(time.Hour)
+	for projectId, s := range samples {
+		acc, ok := current[projectId]
+		if !ok {
+			acc = &accumulator{
+				record: HourlyRecord{
+					ProjectId: projectId,
+					ClusterId: strings.Split(projectId, ":")[0],
+					Hour:      hour,
+				},
+			}
+			current[projectId] = acc
+		}
+		acc.add(s)
+	}
+}
+
+// dropCurrent forgets the hours in progress
+func dropCurrent() {
+	mu.Lock()
+	defer mu.Unlock()
+	current = map[string]*accumulator{}
+}
+
+// closeHour moves the accumulators of past hours to the outbox, the projects
+// that were deleted during the hour are not sampled anymore and are gone after
+// their last record
+func closeHour(now time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	hour := now.UTC().Truncate(time.Hour)
+	for projectId, acc := range current {
+		if !acc.record.Hour.Before(hour) {
+			continue
+		}
+		rec := acc.snapshot()
+		rec.Complete = true
+		delete(current, projectId)
+		outbox = append(outbox, rec)
+	}
+	trimOutbox()
+}
+
+// trimOutbox drops the oldest records beyond maxOutboxRecords, mu must be held
+func trimOutbox() {
+	if len(outbox) <= maxOutboxRecords {
+		return
+	}
+	dropped := len(outbox) - maxOutboxRecords
+	logger.Warn("usage: outbox full, dropping the oldest records", "dropped", dropped, "oldest", outbox[0].Hour.Format(time.RFC3339))
+	outbox = outbox[dropped:]
+}
+
+func (a *accumulator) add(s sample) {
+	a.record.Samples++
+	a.cpuSum += s.CpuRequestsMilli
+	a.memSum += s.MemoryRequestsBytes
+	a.storeSum += s.StorageBytes
+	if s.CpuRequestsMilli > a.record.MaxCpuRequestsMilli {
+		a.record.MaxCpuRequestsMilli = s.CpuRequestsMilli
+	}
+	if s.MemoryRequestsBytes > a.record.MaxMemoryRequestsBytes {
+		a.record.MaxMemoryRequestsBytes = s.MemoryRequestsBytes
+	}
+	if s.StorageBytes > a.record.MaxStorageBytes {
+		a.record.MaxStorageBytes = s.StorageBytes
+	}
+	if s.LoadBalancers > a.record.MaxLoadBalancers {
+		a.record.MaxLoadBalancers = s.LoadBalancers
+	}
+}
+
+func (a *accumulator) snapshot() HourlyRecord {
+	rec := a.record
+	if rec.Samples > 0 {
+		n := int64(rec.Samples)
+		rec.AvgCpuRequestsMilli = a.cpuSum / n
+		rec.AvgMemoryRequestsBytes = a.memSum / n
+		rec.AvgStorageBytes = a.storeSum / n
+	}
+	return rec
+}
+
+func pushUsage(ctx context.Context, records []HourlyRecord) error {
+	billingURL := config.Get().BillingURL
+
+	b, err := json.Marshal(ReqDataPushUsage{Records: records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", billingURL, "/v1/usage"), bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := metrics.Client(metrics.UpstreamBilling)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushing usage records failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
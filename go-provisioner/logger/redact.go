@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// the fields whose name contains one of these words never reach the logs,
+// whatever their value
+var sensitiveWords = []string{
+	"token",
+	"password",
+	"passwd",
+	"secret",
+	"authorization",
+	"apikey",
+	"cookie",
+	"kubeconfig",
+	"privatekey",
+	"stringdata",
+}
+
+// secrets found inside free text, such as the messages or the bodies of the
+// upstream responses
+var sensitivePatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// "token": "value" in a JSON document
+	{regexp.MustCompile(`(?i)("[a-z0-9_\-]*(?:token|password|passwd|secret|kubeconfig|apikey)[a-z0-9_\-]*"\s*:\s*)"(?:[^"\\]|\\.)*"`), `$1"` + redacted + `"`},
+	// token=value in a query string or a form
+	{regexp.MustCompile(`(?i)\b([a-z0-9_\-]*(?:token|password|passwd|secret|apikey)[a-z0-9_\-]*=)[^&\s"]+`), `${1}` + redacted},
+	// authorization header values
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-z0-9\-._~+/:=]{8,}`), `$1 ` + redacted},
+	// Rancher api tokens
+	{regexp.MustCompile(`\btoken-[a-z0-9]{5}:[a-z0-9]{20,}\b`), redacted},
+}
+
+// Exportable functions
+
+// Redact returns s without the secrets it may contain
+func Redact(s string) string {
+	return redactString(s)
+}
+
+// Local functions
+
+func redactString(s string) string {
+	for _, p := range sensitivePatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	for _, word := range sensitiveWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue returns the value logged for the field key. Structured values
+// are walked so that their sensitive fields are hidden too.
+func redactValue(key string, v interface{}) interface{} {
+	if isSensitive(key) {
+		return redacted
+	}
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return redactString(value)
+	case []byte:
+		return redactString(string(value))
+	case error:
+		return redactString(value.Error())
+	case fmt.Stringer:
+		return redactString(value.String())
+	case bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return value
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redactString(fmt.Sprint(v))
+	}
+	var tree interface{}
+	err = json.Unmarshal(b, &tree)
+	if err != nil {
+		return redactString(string(b))
+	}
+	return redactTree(tree)
+}
+
+func redactTree(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		// the values of a Kubernetes Secret are secret whatever their name
+		secret := value["kind"] == "Secret"
+		for k, child := range value {
+			if isSensitive(k) || (secret && k == "data") {
+				value[k] = redacted
+				continue
+			}
+			value[k] = redactTree(child)
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = redactTree(child)
+		}
+		return value
+	case string:
+		return redactString(value)
+	}
+	return v
+}
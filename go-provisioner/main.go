@@ -1,23 +1,225 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
 	"github.com/Creometry/dashboard/go-provisioner/auth"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/Creometry/dashboard/go-provisioner/internal/audit"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/Creometry/dashboard/go-provisioner/internal/tracing"
+	"github.com/Creometry/dashboard/go-provisioner/internal/usage"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/Creometry/dashboard/go-provisioner/middleware"
 	"github.com/Creometry/dashboard/go-provisioner/routes"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
+const (
+	defaultShutdownTimeout = 25 * time.Second
+	flushTimeout           = 5 * time.Second
+)
+
 func main() {
 
-	auth.CreateInClusterClient()
-	//auth.CreateOutClusterClient()
-	app := fiber.New()
+	// the libraries still writing to the standard logger go through the
+	// structured one
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer())
+
+	if err := config.Load(); err != nil {
+		logger.Fatal("cannot load the configuration", "error", err)
+	}
+	setLogLevel()
+	config.OnReload(setLogLevel)
+
+	// SIGTERM is sent by Kubernetes before killing the pod
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go config.Watch(ctx)
+
+	shutdownTracing, err := tracing.Init("go-provisioner")
+	if err != nil {
+		logger.Fatal("cannot set up the tracing", "error", err)
+	}
+
+	kubeconfig := flag.String("kubeconfig", "", "path to a kubeconfig file, KUBECONFIG or the in-cluster config are used when empty")
+	kubeContext := flag.String("context", "", "kubeconfig context to use instead of the current one")
+	flag.Parse()
+
+	auth.CreateKubernetesClient(*kubeconfig, *kubeContext)
 
+	if flag.Arg(0) == "gc" {
+		runGC(ctx, flag.Args()[1:])
+		shutdownTracing(context.Background())
+		return
+	}
+
+	jobs := sync.WaitGroup{}
+	for _, job := range []func(context.Context){
+		project.StartTrialSweeper,
+		usage.StartMetering,
+		project.StartReconciler,
+	} {
+		jobs.Add(1)
+		go func(job func(context.Context)) {
+			defer jobs.Done()
+			job(ctx)
+		}(job)
+	}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apperror.Handler,
+		// the client ip is only read from PROXY_HEADER when the request comes
+		// from one of the TRUSTED_PROXIES, the callers could forge it otherwise
+		ProxyHeader:             config.Get().ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          getTrustedProxies(),
+	})
+
+	app.Use(middleware.Recover)
+	app.Use(middleware.RequestId)
+	app.Use(middleware.Metrics)
+	app.Use(middleware.Tracing)
 	app.Use(cors.New())
 
 	routes.CreateRoutes(app)
 
-	log.Fatal(app.Listen(":3001"))
+	go func() {
+		if err := app.Listen(":3001"); err != nil {
+			logger.Fatal("server stopped", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(app, &jobs, shutdownTracing)
+}
+
+// shutdown stops accepting requests and waits, until SHUTDOWN_TIMEOUT, for
+// the in-flight requests, the provisionings and the background jobs. The
+// buffered audit events, usage records and spans are flushed last.
+func shutdown(app *fiber.App, jobs *sync.WaitGroup, shutdownTracing func(context.Context) error) {
+	timeout := getShutdownTimeout()
+	logger.Info("shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// fasthttp waits for the open connections without a deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			logger.Error("cannot stop the server", "error", err)
+		}
+	case <-ctx.Done():
+		logger.Warn("requests still running at the shutdown deadline")
+	}
+
+	if err := project.WaitForProvisionings(ctx); err != nil {
+		logger.Warn("provisionings still running at the shutdown deadline")
+	}
+	if err := wait(ctx, jobs); err != nil {
+		logger.Warn("background jobs still running at the shutdown deadline")
+	}
+
+	// the flushes get their own deadline, they matter most when the previous
+	// steps used all of theirs
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer flushCancel()
+	if err := usage.Flush(flushCtx); err != nil {
+		logger.Error("cannot flush the usage records", "error", err)
+	}
+	if err := audit.Close(); err != nil {
+		logger.Error("cannot close the audit sink", "error", err)
+	}
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("cannot flush the spans", "error", err)
+	}
+	logger.Info("shut down")
+}
+
+// wait blocks until wg is done or ctx is done
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getTrustedProxies returns the ips and ranges of TRUSTED_PROXIES
+func getTrustedProxies() []string {
+	res := []string{}
+	for _, proxy := range strings.Split(config.Get().TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			res = append(res, proxy)
+		}
+	}
+	return res
+}
+
+// getShutdownTimeout returns SHUTDOWN_TIMEOUT, it should stay below the
+// termination grace period of the pod
+func getShutdownTimeout() time.Duration {
+	return config.Get().Duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+}
+
+// runGC runs a single garbage collection and prints the orphans found
+func runGC(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report the orphans")
+	minAge := fs.String("min-age", "", "only delete the orphans older than this duration (default GC_MIN_AGE)")
+	maxDeletions := fs.Int("max-deletions", 0, "delete at most this many orphans (default GC_MAX_DELETIONS)")
+	fs.Parse(args)
+
+	req := project.ReqDataGC{DryRun: *dryRun, MinAge: *minAge, MaxDeletions: *maxDeletions}
+	if err := req.Validate(); err != nil {
+		logger.Fatal("invalid gc arguments", "error", err)
+	}
+	data, err := project.CollectGarbage(ctx, req)
+	if err != nil {
+		logger.Fatal("gc failed", "error", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		logger.Fatal("cannot print the gc report", "error", err)
+	}
+}
+
+// setLogLevel applies LOG_LEVEL, info when it is not set
+func setLogLevel() {
+	name := config.Get().LogLevel
+	if name == "" {
+		logger.SetLevel(logger.LevelInfo)
+		return
+	}
+	level, err := logger.ParseLevel(name)
+	if err != nil {
+		logger.Warn("invalid LOG_LEVEL, using info", "value", name)
+	}
+	logger.SetLevel(level)
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin only lets through the requests bearing the ADMIN_TOKEN secret.
+// The routes are disabled when the secret is not set.
+func RequireAdmin(c *fiber.Ctx) error {
+	adminToken := config.Get().AdminToken
+	if adminToken == "" {
+		return apperror.New(apperror.CodeForbidden, "admin routes are disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(BearerToken(c)), []byte(adminToken)) != 1 {
+		return apperror.New(apperror.CodeUnauthorized, "invalid admin token")
+	}
+	return c.Next()
+}
+
+// RequireIntrospection only lets through the services bearing the
+// INTROSPECTION_TOKEN secret, the introspection would otherwise tell anyone
+// whether a token is valid. It is disabled when the secret is not set.
+func RequireIntrospection(c *fiber.Ctx) error {
+	introspectionToken := config.Get().IntrospectionToken
+	if introspectionToken == "" {
+		return apperror.New(apperror.CodeForbidden, "token introspection is disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(BearerToken(c)), []byte(introspectionToken)) != 1 {
+		return apperror.New(apperror.CodeUnauthorized, "invalid introspection token")
+	}
+	return c.Next()
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/audit"
+	"github.com/Creometry/dashboard/go-provisioner/internal/cluster"
+	"github.com/Creometry/dashboard/go-provisioner/internal/project"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalAuditProjectId lets a controller name the project of a call whose
+// route has no projectId, e.g. the project it just provisioned
+const LocalAuditProjectId = "auditProjectId"
+
+// actors resolved from Rancher tokens are cached by token hash
+const actorCacheTTL = 5 * time.Minute
+
+type cachedActor struct {
+	userId    string
+	expiresAt time.Time
+}
+
+var actorCache sync.Map
+
+// Audit records the call in the audit log once the controller has answered,
+// whatever the result
+func Audit(action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		// the returned errors are only rendered by the error handler afterwards
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = apperror.From(err).Status
+		}
+
+		event := audit.Event{
+			Time:       time.Now().UTC(),
+			RequestId:  requestId(c),
+			Actor:      actor(c),
+			Action:     action,
+			ProjectId:  auditProjectId(c),
+			TargetUser: c.Params("userId"),
+			Method:     c.Method(),
+			Path:       c.Path(),
+			Status:     status,
+			Result:     audit.ResultSuccess,
+		}
+		if status >= fiber.StatusBadRequest {
+			event.Result = audit.ResultFailure
+			event.Error = responseError(c, err)
+		}
+		audit.Record(event)
+
+		return err
+	}
+}
+
+func requestId(c *fiber.Ctx) string {
+	if id, ok := c.Locals(LocalRequestId).(string); ok {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
+
+// actor returns the user behind the api token or the Rancher token of the call
+func actor(c *fiber.Ctx) string {
+	if t, ok := ApiToken(c); ok {
+		return t.UserId
+	}
+	raw := BearerToken(c)
+	if raw == "" {
+		return audit.ActorAnonymous
+	}
+
+	sum := sha256.Sum256([]byte(raw))
+	key := hex.EncodeToString(sum[:])
+	if v, ok := actorCache.Load(key); ok {
+		cached := v.(cachedActor)
+		if time.Now().Before(cached.expiresAt) {
+			return cached.userId
+		}
+		actorCache.Delete(key)
+	}
+
+	userId, err := project.GetCurrentUser(c.UserContext(), raw)
+	if err != nil || userId == "" {
+		return audit.ActorAnonymous
+	}
+	actorCache.Store(key, cachedActor{userId: userId, expiresAt: time.Now().Add(actorCacheTTL)})
+	return userId
+}
+
+func auditProjectId(c *fiber.Ctx) string {
+	if prId, ok := c.Locals(LocalAuditProjectId).(string); ok {
+		return prId
+	}
+	projectId := c.Params("projectId")
+	if projectId == "" {
+		return ""
+	}
+	prId, err := cluster.FullProjectId(projectId)
+	if err != nil {
+		return projectId
+	}
+	return prId
+}
+
+// responseError returns the error message the controller answered with
+func responseError(c *fiber.Ctx, err error) string {
+	if err != nil {
+		return apperror.From(err).Message
+	}
+	body := struct {
+		Error string `json:"error"`
+	}{}
+	if json.Unmarshal(c.Response().Body(), &body) != nil {
+		return ""
+	}
+	return body.Error
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/token"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalApiToken is the key of the verified token.ApiToken in the request locals
+const LocalApiToken = "apiToken"
+
+// Authenticate verifies the api tokens sent as bearer tokens and rejects the
+// requests they are not scoped for. Requests without an api token are passed
+// through unchanged.
+func Authenticate(c *fiber.Ctx) error {
+	raw := BearerToken(c)
+	if !token.IsApiToken(raw) {
+		return c.Next()
+	}
+
+	t, err := token.Verify(c.UserContext(), raw)
+	if err != nil {
+		return err
+	}
+
+	if t.Scope == token.ScopeReadOnly && c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return apperror.New(apperror.CodeForbidden, "api token is read-only")
+	}
+
+	// the controllers check that the project of the route is the one of the token
+	if t.ProjectId != "" && !isProjectRoute(c.Path()) {
+		return apperror.New(apperror.CodeForbidden, "api token is restricted to project "+t.ProjectId)
+	}
+
+	c.Locals(LocalApiToken, t)
+	return c.Next()
+}
+
+// BearerToken returns the token of the Authorization header
+func BearerToken(c *fiber.Ctx) string {
+	return strings.TrimSpace(strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "))
+}
+
+// ApiToken returns the api token the request was authenticated with
+func ApiToken(c *fiber.Ctx) (token.ApiToken, bool) {
+	t, ok := c.Locals(LocalApiToken).(token.ApiToken)
+	return t, ok
+}
+
+func isProjectRoute(path string) bool {
+	return strings.HasPrefix(path, "/api/v1/projects/") || strings.HasPrefix(path, "/api/v1/team/")
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitLogin locks the client ips, and the usernames tried from each ip, out
+// after too many rejected credentials. The usernames are also limited across
+// the ips, with a higher threshold and a short backoff, so that a guessing
+// spread over many ips is slowed down without letting anybody lock the owner
+// of an account out for long. A successful login resets its username, not
+// its ip, so that one valid account cannot be used to keep guessing the
+// others.
+func LimitLogin(c *fiber.Ctx) error {
+	body := struct {
+		Username string `json:"username"`
+	}{}
+	_ = c.BodyParser(&body)
+
+	ipKey, userIPKey, userKey := loginKeys(body.Username, c.IP())
+	keys := []string{ipKey}
+	if userKey != "" {
+		keys = append(keys, userIPKey, userKey)
+	}
+
+	now := time.Now()
+	retryAfter := time.Duration(0)
+	for _, key := range keys {
+		if d := ratelimit.RetryAfter(key, now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	if retryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return apperror.New(apperror.CodeTooManyRequests, "too many failed logins, retry later")
+	}
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apperror.From(err).Status
+	}
+	userIPPolicy, userPolicy, ipPolicy := ratelimit.LoginPolicies()
+	switch {
+	case status == fiber.StatusUnauthorized || status == fiber.StatusForbidden:
+		// only the rejected credentials count, not the outages of Rancher
+		ratelimit.Fail(ipKey, ipPolicy, now)
+		if userKey != "" {
+			ratelimit.Fail(userIPKey, userIPPolicy, now)
+			ratelimit.Fail(userKey, userPolicy, now)
+		}
+	case status < fiber.StatusBadRequest && userKey != "":
+		ratelimit.Succeed(userIPKey)
+		ratelimit.Succeed(userKey)
+	}
+	return err
+}
+
+// loginKeys returns the rate limit keys of the client ip, of the username
+// tried from that ip and of the username, the username keys are empty when
+// no username is given
+func loginKeys(username string, ip string) (string, string, string) {
+	ipKey := "login:ip:" + ip
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" {
+		return ipKey, "", ""
+	}
+	return ipKey, "login:user:" + username + ":ip:" + ip, "login:user:" + username
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/ratelimit"
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLoginKeys(t *testing.T) {
+	tests := []struct {
+		name          string
+		username      string
+		wantUserIPKey string
+		wantUserKey   string
+	}{
+		{name: "username", username: "alice", wantUserIPKey: "login:user:alice:ip:10.0.0.1", wantUserKey: "login:user:alice"},
+		{name: "case and spaces", username: " Alice ", wantUserIPKey: "login:user:alice:ip:10.0.0.1", wantUserKey: "login:user:alice"},
+		{name: "no username", username: ""},
+		{name: "blank username", username: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipKey, userIPKey, userKey := loginKeys(tt.username, "10.0.0.1")
+			if ipKey != "login:ip:10.0.0.1" || userIPKey != tt.wantUserIPKey || userKey != tt.wantUserKey {
+				t.Fatalf("loginKeys() = %q, %q, %q, want %q, %q, %q", ipKey, userIPKey, userKey, "login:ip:10.0.0.1", tt.wantUserIPKey, tt.wantUserKey)
+			}
+		})
+	}
+}
+
+func TestLimitLogin(t *testing.T) {
+	logger.SetOutput(io.Discard)
+
+	// the default policies: 5 failures per username and ip, 20 per ip
+	tests := []struct {
+		name       string
+		attempts   []string
+		wantStatus int
+	}{
+		{name: "under the limit", attempts: []string{"alice:bad", "alice:bad", "alice:bad", "alice:bad", "alice:good"}, wantStatus: http.StatusOK},
+		{name: "username locked out", attempts: []string{"alice:bad", "alice:bad", "alice:bad", "alice:bad", "alice:bad", "alice:good"}, wantStatus: http.StatusTooManyRequests},
+		{name: "other username on the ip", attempts: []string{"alice:bad", "alice:bad", "alice:bad", "alice:bad", "alice:bad", "bob:good"}, wantStatus: http.StatusOK},
+		{
+			name: "success resets the username",
+			attempts: []string{
+				"alice:bad", "alice:bad", "alice:bad", "alice:bad", "alice:good",
+				"alice:bad", "alice:bad", "alice:bad", "alice:bad", "alice:good",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "success does not reset the ip",
+			attempts: []string{
+				"a:bad", "b:bad", "c:bad", "d:bad", "e:bad", "f:bad", "g:bad", "h:bad", "i:bad", "j:bad",
+				"k:bad", "l:bad", "m:bad", "n:bad", "o:bad", "p:bad", "q:bad", "r:bad", "s:bad", "bob:good",
+				"t:bad", "bob:good",
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratelimit.SetStore(ratelimit.NewMemoryStore())
+			app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler})
+			app.Post("/login", LimitLogin, func(c *fiber.Ctx) error {
+				body := struct {
+					Password string `json:"password"`
+				}{}
+				if err := c.BodyParser(&body); err != nil || body.Password != "good" {
+					return apperror.New(apperror.CodeUnauthorized, "invalid credentials")
+				}
+				return c.SendStatus(http.StatusOK)
+			})
+
+			status := 0
+			for _, attempt := range tt.attempts {
+				parts := strings.SplitN(attempt, ":", 2)
+				req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"`+parts[0]+`","password":"`+parts[1]+`"}`))
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+				resp.Body.Close()
+				status = resp.StatusCode
+			}
+			if status != tt.wantStatus {
+				t.Fatalf("last attempt answered %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// LocalRequestId is the local holding the id of the request
+const LocalRequestId = "requestid"
+
+// the ids given by the callers are kept only when they look like ids, they
+// end up in the logs and in the headers of the upstream calls
+const maxRequestIdLength = 128
+
+// RequestId gives every request an id, the one of the X-Request-Id header
+// when the caller sent one. The id is sent back in the response and carried
+// by the user context, so that the logs and the upstream calls of the request
+// share it.
+func RequestId(c *fiber.Ctx) error {
+	id := c.Get(logger.HeaderRequestId)
+	if !validRequestId(id) {
+		id = utils.UUIDv4()
+	}
+	c.Set(logger.HeaderRequestId, id)
+	c.Locals(LocalRequestId, id)
+	c.SetUserContext(logger.WithRequestId(c.UserContext(), id))
+	return c.Next()
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"github.com/Creometry/dashboard/go-provisioner/apperror"
+	"github.com/Creometry/dashboard/go-provisioner/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span per request, continuing the trace of the
+// caller when the request carries a traceparent header. The context of the
+// span is stored as the user context of the request so that the handlers can
+// pass it to the upstream calls.
+func Tracing(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+	ctx, span := tracing.Start(ctx, c.Method()+" "+c.Path(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod(c.Method()),
+			semconv.HTTPTarget(c.OriginalURL()),
+			semconv.HTTPUserAgent(c.Get(fiber.HeaderUserAgent)),
+		),
+	)
+	defer span.End()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	// the route is only known once the request has been matched, it keeps the
+	// ids of the paths out of the span names
+	span.SetName(c.Method() + " " + c.Route().Path)
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apperror.From(err).Status
+		span.RecordError(err)
+	}
+	span.SetAttributes(semconv.HTTPRoute(c.Route().Path), semconv.HTTPStatusCode(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+	return err
+}
+
+// headerCarrier exposes the request headers to the propagators
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key string, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := []string{}
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -3,18 +3,48 @@ package routes
 import (
 	pr "github.com/Creometry/dashboard/go-provisioner/controllers"
 	gh "github.com/Creometry/dashboard/go-provisioner/controllers/github"
+	"github.com/Creometry/dashboard/go-provisioner/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func CreateRoutes(app *fiber.App) {
 
-	v1 := app.Group("/api/v1")
+	// probes, outside of the api so that they need no authentication
+	app.Get("/healthz", pr.Healthz)
+	app.Get("/readyz", pr.Readyz)
+	app.Get("/status", pr.GetStatus)
+	app.Get("/metrics", pr.GetMetrics)
+
+	v1 := app.Group("/api/v1", middleware.Authenticate)
 	v1.Get("/github/exchange/:code", gh.GetAccessToken)
-	v1.Post("/provisionProject", pr.ProvisionProject)
-	v1.Post("/login", pr.Login)
-	v1.Post("/register", pr.Register)
+	v1.Post("/provisionProject", middleware.Audit("project.provision"), pr.ProvisionProject)
+	v1.Get("/templates", pr.ListTemplates)
+	v1.Get("/clusters", pr.ListClusters)
+	v1.Get("/placement", pr.PreviewPlacement)
+	v1.Post("/login", middleware.LimitLogin, pr.Login)
+	v1.Post("/register", middleware.Audit("user.register"), pr.Register)
 	v1.Get("/team/:projectId", pr.ListTeamMembers)
-	v1.Post("/team/:projectId/:userId", pr.AddTeamMember)
-	v1.Post("/kubeconfig", pr.GenerateKubeConfig)
+	v1.Post("/team/:projectId/:userId", middleware.Audit("team.add"), pr.AddTeamMember)
+	v1.Post("/kubeconfig", middleware.Audit("kubeconfig.generate"), pr.GenerateKubeConfig)
+	// the billing service suspends and reactivates the projects
+	v1.Post("/projects/:projectId/suspend", middleware.Audit("project.suspend"), middleware.RequireAdmin, pr.SuspendProject)
+	v1.Post("/projects/:projectId/reactivate", middleware.Audit("project.reactivate"), middleware.RequireAdmin, pr.ReactivateProject)
+	v1.Post("/projects/:projectId/convert", middleware.Audit("project.convert"), pr.ConvertTrialProject)
+	v1.Get("/projects/:projectId/usage", pr.GetProjectUsage)
+	v1.Get("/projects/:projectId/quota", pr.GetProjectQuota)
+	v1.Get("/projects/:projectId/namespaces", pr.ListNamespaces)
+	v1.Get("/projects/:projectId/audit", pr.GetProjectAudit)
+	v1.Post("/projects/:projectId/namespaces", middleware.Audit("namespace.add"), pr.AddNamespace)
+	v1.Delete("/projects/:projectId/namespaces/:namespace", middleware.Audit("namespace.delete"), pr.DeleteNamespace)
+	v1.Post("/projects/:projectId/kubeconfig", middleware.Audit("kubeconfig.generate"), pr.GenerateProjectKubeConfig)
+	v1.Get("/projects/:projectId/registries", pr.ListRegistries)
+	v1.Post("/projects/:projectId/registries", middleware.Audit("registry.set"), pr.SetRegistry)
+	v1.Put("/projects/:projectId/registries/:name", middleware.Audit("registry.set"), pr.SetRegistry)
+	v1.Delete("/projects/:projectId/registries/:name", middleware.Audit("registry.delete"), pr.DeleteRegistry)
+	v1.Post("/tokens", middleware.Audit("token.create"), pr.CreateApiToken)
+	v1.Get("/tokens", pr.ListApiTokens)
+	v1.Delete("/tokens/:tokenId", middleware.Audit("token.revoke"), pr.RevokeApiToken)
+	v1.Post("/tokens/introspect", middleware.RequireIntrospection, pr.IntrospectApiToken)
+	v1.Post("/admin/gc", middleware.Audit("admin.gc"), middleware.RequireAdmin, pr.CollectGarbage)
 }
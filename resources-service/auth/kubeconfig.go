@@ -1,9 +1,10 @@
 package auth
 
 import (
-	"flag"
-	"log"
+	"os"
 
+	"github.com/Creometry/resources-service/logger"
+	"github.com/Creometry/resources-service/metrics"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -11,53 +12,58 @@ import (
 )
 
 var MyClientSet *kubernetes.Clientset
-var MyInClusterClientSet *kubernetes.Clientset
 var MyExtensionsClientSet *clientset.Clientset
 var Config *rest.Config
 
-func CreateInClusterClient() {
-	config, err := rest.InClusterConfig()
+// CreateKubernetesClient creates the clients from the kubeconfig file and
+// context given, or from the KUBECONFIG environment variable. Without any of
+// them the in-cluster config is used.
+func CreateKubernetesClient(kubeconfig string, context string) {
+	config, err := LoadConfig(kubeconfig, context)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal("cannot load the kubernetes config", "error", err)
 	}
+	config.Wrap(metrics.WrapTransport)
 
-	clientset, err := kubernetes.NewForConfig(config)
+	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal("cannot create the kubernetes client", "error", err)
 	}
-	MyInClusterClientSet = clientset
-}
-
-func CreateKubernetesClient() {
-	kubeconfig := flag.String("kubeconfig", "./kubeconfig.yaml", "")
-	flag.Parse()
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 
+	extensionsClientSet, err := clientset.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal("cannot create the apiextensions client", "error", err)
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Fatal(err)
-	}
-	MyClientSet = clientset
+	MyClientSet = clientSet
+	MyExtensionsClientSet = extensionsClientSet
+	Config = config
 }
 
-func CreateExtensionsClient() {
-	config, err := clientcmd.BuildConfigFromFlags("", "./kubeconfig.yaml")
-
-	if err != nil {
-		log.Fatal(err)
+// LoadConfig returns the in-cluster config unless a kubeconfig file, the
+// KUBECONFIG environment variable or a context is given
+func LoadConfig(kubeconfig string, context string) (*rest.Config, error) {
+	if kubeconfig == "" && context == "" && os.Getenv("KUBECONFIG") == "" {
+		logger.Info("using the in-cluster kubernetes config")
+		return rest.InClusterConfig()
 	}
 
-	clientset, err := clientset.NewForConfig(config)
+	// the default rules read KUBECONFIG, then ~/.kube/config
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
 
+	rawConfig, err := clientConfig.RawConfig()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
-	MyExtensionsClientSet = clientset
-	Config = config
-
+	current := rawConfig.CurrentContext
+	if context != "" {
+		current = context
+	}
+	logger.Info("using a kubernetes context", "context", current)
+	return clientConfig.ClientConfig()
 }
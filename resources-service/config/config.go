@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Creometry/resources-service/logger"
+)
+
+const (
+	defaultProvisionerURL  = "http://go-provisioner-svc:3001"
+	defaultTracingExporter = "none"
+	defaultShutdownTimeout = 25 * time.Second
+)
+
+var current atomic.Value
+
+// Exportable functions
+
+// Load reads and validates the environment variables, the configuration is
+// only replaced when it is valid
+func Load() error {
+	c, errs := read()
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	current.Store(c)
+	return nil
+}
+
+// Get returns the current configuration, Load must have been called
+func Get() *Config {
+	c, _ := current.Load().(*Config)
+	if c == nil {
+		return &Config{
+			ProvisionerURL:     defaultProvisionerURL,
+			TracingExporter:    defaultTracingExporter,
+			TracingSampleRatio: 1,
+			ShutdownTimeout:    defaultShutdownTimeout,
+		}
+	}
+	return c
+}
+
+// Local functions
+
+func read() (*Config, []string) {
+	errs := []string{}
+	c := &Config{
+		ProvisionerURL:     getEnv("PROVISIONER_URL", defaultProvisionerURL),
+		IntrospectionToken: os.Getenv("INTROSPECTION_TOKEN"),
+		LogLevel:           os.Getenv("LOG_LEVEL"),
+		TracingExporter:    getEnv("TRACING_EXPORTER", defaultTracingExporter),
+		TracingSampleRatio: 1,
+		ShutdownTimeout:    defaultShutdownTimeout,
+	}
+
+	if err := validateURL(c.ProvisionerURL); err != nil {
+		errs = append(errs, fmt.Sprintf("PROVISIONER_URL: %v", err))
+	}
+	if c.LogLevel != "" {
+		if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+			errs = append(errs, fmt.Sprintf("LOG_LEVEL: %v", err))
+		}
+	}
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			errs = append(errs, fmt.Sprintf("TRACING_SAMPLE_RATIO: %s is not a number between 0 and 1", value))
+		}
+		c.TracingSampleRatio = ratio
+	}
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			errs = append(errs, fmt.Sprintf("SHUTDOWN_TIMEOUT: %s is not a positive duration", value))
+		}
+		c.ShutdownTimeout = d
+	}
+	return c, errs
+}
+
+func getEnv(name string, defaultValue string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func validateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s is not an http(s) url", value)
+	}
+	return nil
+}
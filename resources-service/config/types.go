@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// Config holds the environment variables of the service, read and checked
+// once at startup
+type Config struct {
+	// ProvisionerURL is the go-provisioner service introspecting the api
+	// tokens
+	ProvisionerURL string
+	// IntrospectionToken authenticates the service to the token
+	// introspection of go-provisioner
+	IntrospectionToken string
+
+	LogLevel           string
+	TracingExporter    string
+	TracingSampleRatio float64
+	ShutdownTimeout    time.Duration
+}
@@ -21,11 +21,9 @@ import (
 
 func GetAllPods(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	pods, err := pod.GetPods(ns)
+	pods, err := pod.GetPods(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": pods,
@@ -35,11 +33,9 @@ func GetAllPods(c *fiber.Ctx) error {
 func GetPod(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	podName := c.Params("pod")
-	pod, err := pod.GetPod(ns, podName)
+	pod, err := pod.GetPod(c.UserContext(), ns, podName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": pod,
@@ -48,11 +44,9 @@ func GetPod(c *fiber.Ctx) error {
 
 func GetAllServices(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	services, err := service.GetServices(ns)
+	services, err := service.GetServices(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": services,
@@ -62,11 +56,9 @@ func GetAllServices(c *fiber.Ctx) error {
 func GetService(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	serviceName := c.Params("service")
-	service, err := service.GetService(ns, serviceName)
+	service, err := service.GetService(c.UserContext(), ns, serviceName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": service,
@@ -75,11 +67,9 @@ func GetService(c *fiber.Ctx) error {
 
 func GetAllDeployments(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	deployments, err := deployment.GetDeployments(ns)
+	deployments, err := deployment.GetDeployments(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": deployments,
@@ -89,11 +79,9 @@ func GetAllDeployments(c *fiber.Ctx) error {
 func GetDeployment(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	deploymentName := c.Params("deployment")
-	deployment, err := deployment.GetDeployment(ns, deploymentName)
+	deployment, err := deployment.GetDeployment(c.UserContext(), ns, deploymentName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": deployment,
@@ -102,11 +90,9 @@ func GetDeployment(c *fiber.Ctx) error {
 
 func GetAllConfigMaps(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	configMaps, err := configmap.GetConfigMaps(ns)
+	configMaps, err := configmap.GetConfigMaps(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": configMaps,
@@ -116,11 +102,9 @@ func GetAllConfigMaps(c *fiber.Ctx) error {
 func GetConfigMap(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	configMapName := c.Params("configmap")
-	configMap, err := configmap.GetConfigMap(ns, configMapName)
+	configMap, err := configmap.GetConfigMap(c.UserContext(), ns, configMapName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": configMap,
@@ -129,11 +113,9 @@ func GetConfigMap(c *fiber.Ctx) error {
 
 func GetAllSecrets(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	secrets, err := secret.GetSecrets(ns)
+	secrets, err := secret.GetSecrets(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": secrets,
@@ -143,11 +125,9 @@ func GetAllSecrets(c *fiber.Ctx) error {
 func GetSecret(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	secretName := c.Params("secret")
-	secret, err := secret.GetSecret(ns, secretName)
+	secret, err := secret.GetSecret(c.UserContext(), ns, secretName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": secret,
@@ -156,11 +136,9 @@ func GetSecret(c *fiber.Ctx) error {
 
 func GetAllPersistentVolumeClaims(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	pvc, err := persistentvolumeclaim.GetPersistentVolumeClaims(ns)
+	pvc, err := persistentvolumeclaim.GetPersistentVolumeClaims(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": pvc,
@@ -170,11 +148,9 @@ func GetAllPersistentVolumeClaims(c *fiber.Ctx) error {
 func GetPersistentVolumeClaim(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	pvcName := c.Params("pvc")
-	pvc, err := persistentvolumeclaim.GetPersistentVolumeClaim(ns, pvcName)
+	pvc, err := persistentvolumeclaim.GetPersistentVolumeClaim(c.UserContext(), ns, pvcName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": pvc,
@@ -183,11 +159,9 @@ func GetPersistentVolumeClaim(c *fiber.Ctx) error {
 
 func GetAllStatefulSets(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	statefulSets, err := statefulset.GetStatefulSets(ns)
+	statefulSets, err := statefulset.GetStatefulSets(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": statefulSets,
@@ -197,11 +171,9 @@ func GetAllStatefulSets(c *fiber.Ctx) error {
 func GetStatefulSet(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	statefulSetName := c.Params("sts")
-	statefulSet, err := statefulset.GetStatefulSet(ns, statefulSetName)
+	statefulSet, err := statefulset.GetStatefulSet(c.UserContext(), ns, statefulSetName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": statefulSet,
@@ -210,11 +182,9 @@ func GetStatefulSet(c *fiber.Ctx) error {
 
 func GetAllJobs(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	jobs, err := job.GetJobs(ns)
+	jobs, err := job.GetJobs(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": jobs,
@@ -224,11 +194,9 @@ func GetAllJobs(c *fiber.Ctx) error {
 func GetJob(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	jobName := c.Params("job")
-	job, err := job.GetJob(ns, jobName)
+	job, err := job.GetJob(c.UserContext(), ns, jobName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": job,
@@ -237,11 +205,9 @@ func GetJob(c *fiber.Ctx) error {
 
 func GetAllCronJobs(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	cronJobs, err := cronjob.GetCronJobs(ns)
+	cronJobs, err := cronjob.GetCronJobs(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": cronJobs,
@@ -251,11 +217,9 @@ func GetAllCronJobs(c *fiber.Ctx) error {
 func GetCronJob(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	cronJobName := c.Params("cronjob")
-	cronJob, err := cronjob.GetCronJob(ns, cronJobName)
+	cronJob, err := cronjob.GetCronJob(c.UserContext(), ns, cronJobName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": cronJob,
@@ -264,11 +228,9 @@ func GetCronJob(c *fiber.Ctx) error {
 
 func GetAllEndpoints(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	endpoints, err := endpoint.GetEndpoints(ns)
+	endpoints, err := endpoint.GetEndpoints(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": endpoints,
@@ -278,11 +240,9 @@ func GetAllEndpoints(c *fiber.Ctx) error {
 func GetEndpoint(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	endpointName := c.Params("endpoint")
-	endpoint, err := endpoint.GetEndpoint(ns, endpointName)
+	endpoint, err := endpoint.GetEndpoint(c.UserContext(), ns, endpointName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": endpoint,
@@ -291,11 +251,9 @@ func GetEndpoint(c *fiber.Ctx) error {
 
 func GetAllIngresses(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	ingresses, err := ingress.GetIngresses(ns)
+	ingresses, err := ingress.GetIngresses(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": ingresses,
@@ -305,11 +263,9 @@ func GetAllIngresses(c *fiber.Ctx) error {
 func GetIngress(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	ingressName := c.Params("ingress")
-	ingress, err := ingress.GetIngress(ns, ingressName)
+	ingress, err := ingress.GetIngress(c.UserContext(), ns, ingressName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": ingress,
@@ -318,11 +274,9 @@ func GetIngress(c *fiber.Ctx) error {
 
 func GetAllEvents(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	events, err := event.GetEvents(ns)
+	events, err := event.GetEvents(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": events,
@@ -331,11 +285,9 @@ func GetAllEvents(c *fiber.Ctx) error {
 
 func GetAllHorizontalPodAutoscalers(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	horizontalPodAutoscalers, err := horizontalpodautoscaler.GetHorizontalPodAutoscalers(ns)
+	horizontalPodAutoscalers, err := horizontalpodautoscaler.GetHorizontalPodAutoscalers(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": horizontalPodAutoscalers,
@@ -345,11 +297,9 @@ func GetAllHorizontalPodAutoscalers(c *fiber.Ctx) error {
 func GetHorizontalPodAutoscaler(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	horizontalPodAutoscalerName := c.Params("horizontalpodautoscaler")
-	horizontalPodAutoscaler, err := horizontalpodautoscaler.GetHorizontalPodAutoscaler(ns, horizontalPodAutoscalerName)
+	horizontalPodAutoscaler, err := horizontalpodautoscaler.GetHorizontalPodAutoscaler(c.UserContext(), ns, horizontalPodAutoscalerName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": horizontalPodAutoscaler,
@@ -358,11 +308,9 @@ func GetHorizontalPodAutoscaler(c *fiber.Ctx) error {
 
 func GetAllCustomResources(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
-	customResources, err := customresource.GetCustomResources(ns)
+	customResources, err := customresource.GetCustomResources(c.UserContext(), ns)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": customResources,
@@ -372,11 +320,9 @@ func GetAllCustomResources(c *fiber.Ctx) error {
 func GetCustomResource(c *fiber.Ctx) error {
 	ns := c.Params("namespace")
 	customResourceName := c.Params("customresource")
-	customResource, err := customresource.GetCustomResource(ns, customResourceName)
+	customResource, err := customresource.GetCustomResource(c.UserContext(), ns, customResourceName)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(fiber.Map{
 		"data": customResource,
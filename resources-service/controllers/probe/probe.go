@@ -0,0 +1,42 @@
+package probe
+
+import (
+	"github.com/Creometry/resources-service/health"
+	"github.com/Creometry/resources-service/metrics"
+	"github.com/gofiber/fiber/v2"
+)
+
+var metricsHandler = metrics.Handler()
+
+// Healthz only tells that the process serves requests, the dependencies are
+// left to Readyz so that an outage does not get the pods restarted
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+func Readyz(c *fiber.Ctx) error {
+	report := health.Check()
+	if !report.Ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "unavailable",
+			"checks": report.Checks,
+		})
+	}
+	return c.JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+func GetStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"data": health.Check(),
+	})
+}
+
+// GetMetrics serves the Prometheus metrics
+func GetMetrics(c *fiber.Ctx) error {
+	metricsHandler(c.Context())
+	return nil
+}
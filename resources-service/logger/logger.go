@@ -0,0 +1,254 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// HeaderRequestId carries the request id between the services
+const HeaderRequestId = "X-Request-Id"
+
+// Logger writes one JSON object per line, with the fields given to With
+// added to every entry
+type Logger struct {
+	fields []interface{}
+}
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIdKey
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+var (
+	outMu sync.Mutex
+	out   io.Writer = os.Stderr
+
+	minLevel = int32(LevelInfo)
+
+	root = &Logger{}
+)
+
+// Exportable functions
+
+// ParseLevel returns the level named name, debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for level, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %s", name)
+}
+
+// SetLevel drops the entries below level
+func SetLevel(level Level) {
+	atomic.StoreInt32(&minLevel, int32(level))
+}
+
+// SetOutput replaces the writer of the entries, stderr by default
+func SetOutput(w io.Writer) {
+	outMu.Lock()
+	defer outMu.Unlock()
+	out = w
+}
+
+// Writer returns a writer logging every line at the info level, for the
+// libraries writing to the standard logger
+func Writer() io.Writer {
+	return stdWriter{}
+}
+
+// With returns a logger adding the key value pairs kv to every entry
+func With(kv ...interface{}) *Logger {
+	return root.With(kv...)
+}
+
+// Debug, Info, Warn and Error log msg with the key value pairs kv
+func Debug(msg string, kv ...interface{}) {
+	root.log(LevelDebug, msg, kv)
+}
+
+func Info(msg string, kv ...interface{}) {
+	root.log(LevelInfo, msg, kv)
+}
+
+func Warn(msg string, kv ...interface{}) {
+	root.log(LevelWarn, msg, kv)
+}
+
+func Error(msg string, kv ...interface{}) {
+	root.log(LevelError, msg, kv)
+}
+
+// Fatal logs at the error level and exits
+func Fatal(msg string, kv ...interface{}) {
+	root.log(LevelError, msg, kv)
+	os.Exit(1)
+}
+
+// With returns a logger adding the key value pairs kv to every entry
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{fields: fields}
+}
+
+// Debug, Info, Warn and Error log msg with the key value pairs kv and the
+// fields of l
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger of ctx, the root logger when ctx has none
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return root
+	}
+	if l, ok := ctx.Value(loggerKey).(*Logger); ok {
+		return l
+	}
+	return root
+}
+
+// WithRequestId returns a copy of ctx carrying the request id and a logger
+// adding it to every entry
+func WithRequestId(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIdKey, id)
+	return NewContext(ctx, FromContext(ctx).With("requestId", id))
+}
+
+// RequestId returns the request id of ctx, if any
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey).(string)
+	return id
+}
+
+// Transport sends the request id of the context of the calls made through
+// next in their X-Request-Id header
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+// Local functions
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := RequestId(req.Context())
+	if id == "" || req.Header.Get(HeaderRequestId) != "" {
+		return t.next.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set(HeaderRequestId, id)
+	return t.next.RoundTrip(req)
+}
+
+type stdWriter struct{}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	root.log(LevelInfo, strings.TrimRight(string(p), "\n"), nil)
+	return len(p), nil
+}
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if int32(level) < atomic.LoadInt32(&minLevel) {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, levelNames[level])
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, redactString(msg))
+	writeFields(buf, l.fields)
+	writeFields(buf, kv)
+	buf.WriteString("}\n")
+
+	outMu.Lock()
+	defer outMu.Unlock()
+	out.Write(buf.Bytes())
+}
+
+func writeFields(buf *bytes.Buffer, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok || i+1 == len(kv) {
+			// a value without a key is kept rather than dropped
+			key = "!BADKEY"
+			buf.WriteByte(',')
+			writeJSON(buf, key)
+			buf.WriteByte(':')
+			writeJSON(buf, redactValue("", kv[i]))
+			i--
+			continue
+		}
+		buf.WriteByte(',')
+		writeJSON(buf, key)
+		buf.WriteByte(':')
+		writeJSON(buf, redactValue(key, kv[i+1]))
+	}
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
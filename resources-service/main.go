@@ -1,25 +1,117 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/Creometry/resources-service/apperror"
 	"github.com/Creometry/resources-service/auth"
+	"github.com/Creometry/resources-service/config"
+	"github.com/Creometry/resources-service/logger"
+	"github.com/Creometry/resources-service/middleware"
 	"github.com/Creometry/resources-service/routes"
+	"github.com/Creometry/resources-service/tracing"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
+const flushTimeout = 5 * time.Second
+
 func main() {
-	auth.CreateInClusterClient()
+	// the libraries still writing to the standard logger go through the
+	// structured one
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer())
+	if err := config.Load(); err != nil {
+		logger.Fatal("cannot load the configuration", "error", err)
+	}
+	setLogLevel()
+
+	// SIGTERM is sent by Kubernetes before killing the pod
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init("resources-service")
+	if err != nil {
+		logger.Fatal("cannot set up the tracing", "error", err)
+	}
+
+	kubeconfig := flag.String("kubeconfig", "", "path to a kubeconfig file, KUBECONFIG or the in-cluster config are used when empty")
+	kubeContext := flag.String("context", "", "kubeconfig context to use instead of the current one")
+	flag.Parse()
 
-	app := fiber.New()
+	auth.CreateKubernetesClient(*kubeconfig, *kubeContext)
 
+	go middleware.SweepIntrospections(ctx)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apperror.Handler,
+	})
+
+	app.Use(middleware.Recover)
+	app.Use(middleware.RequestId)
+	app.Use(middleware.Metrics)
+	app.Use(middleware.Tracing)
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:3000",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowOrigins:  "http://localhost:3000",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-Request-Id",
+		ExposeHeaders: "X-Request-Id",
 	}))
 
 	routes.CreateRoutes(app)
 
-	log.Fatal(app.Listen(":3002"))
+	go func() {
+		if err := app.Listen(":3002"); err != nil {
+			logger.Fatal("server stopped", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(app, shutdownTracing)
+}
+
+// shutdown stops accepting requests and waits, until SHUTDOWN_TIMEOUT, for
+// the in-flight ones, then flushes the buffered spans
+func shutdown(app *fiber.App, shutdownTracing func(context.Context) error) {
+	timeout := config.Get().ShutdownTimeout
+	logger.Info("shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// fasthttp waits for the open connections without a deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			logger.Error("cannot stop the server", "error", err)
+		}
+	case <-ctx.Done():
+		logger.Warn("requests still running at the shutdown deadline")
+	}
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer flushCancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("cannot flush the spans", "error", err)
+	}
+	logger.Info("shut down")
+}
+
+// setLogLevel applies LOG_LEVEL, info when it is not set
+func setLogLevel() {
+	name := config.Get().LogLevel
+	if name == "" {
+		return
+	}
+	// the level is checked by config.Load
+	level, _ := logger.ParseLevel(name)
+	logger.SetLevel(level)
 }
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Creometry/resources-service/apperror"
+	"github.com/Creometry/resources-service/auth"
+	"github.com/Creometry/resources-service/config"
+	"github.com/Creometry/resources-service/metrics"
+	"github.com/gofiber/fiber/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	apiTokenPrefix = "crt_"
+	projectIdLabel = "field.cattle.io/projectId"
+	// the active introspections are cached to avoid a call to the
+	// provisioner per request, the revoked and unknown tokens are not
+	introspectionTTL = 30 * time.Second
+	// maxCachedIntrospections bounds the cache, the tokens past it are
+	// introspected on every request until the sweep frees some room
+	maxCachedIntrospections = 10000
+)
+
+type introspection struct {
+	Active    bool   `json:"active"`
+	UserId    string `json:"userId"`
+	Scope     string `json:"scope"`
+	ProjectId string `json:"projectId"`
+}
+
+type cachedIntrospection struct {
+	result    introspection
+	expiresAt time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cachedIntrospection{}
+)
+
+// SweepIntrospections removes the expired introspections from the cache
+// every introspectionTTL until ctx is done
+func SweepIntrospections(ctx context.Context) {
+	ticker := time.NewTicker(introspectionTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweepIntrospections(now)
+		}
+	}
+}
+
+// Authenticate verifies the api tokens issued by go-provisioner and rejects
+// the requests for namespaces outside of the project a token is restricted
+// to. Requests without an api token are passed through unchanged.
+func Authenticate(c *fiber.Ctx) error {
+	raw := strings.TrimSpace(strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "))
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return c.Next()
+	}
+
+	t, err := introspect(c.UserContext(), raw)
+	if err != nil {
+		return apperror.Wrap(apperror.CodeUpstreamUnavailable, err)
+	}
+	if !t.Active {
+		return apperror.New(apperror.CodeUnauthorized, "invalid api token")
+	}
+
+	if t.ProjectId != "" {
+		ok, err := namespaceInProject(c.UserContext(), namespaceFromPath(c.Path()), t.ProjectId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return apperror.New(apperror.CodeForbidden, "api token is restricted to project "+t.ProjectId)
+		}
+	}
+
+	c.Locals("userId", t.UserId)
+	return c.Next()
+}
+
+func introspect(ctx context.Context, raw string) (introspection, error) {
+	sum := sha256.Sum256([]byte(raw))
+	key := hex.EncodeToString(sum[:])
+	if result, ok := cachedResult(key, time.Now()); ok {
+		return result, nil
+	}
+
+	b, err := json.Marshal(map[string]string{"token": raw})
+	if err != nil {
+		return introspection{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/v1/tokens/introspect", config.Get().ProvisionerURL), bytes.NewBuffer(b))
+	if err != nil {
+		return introspection{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the provisioner only answers the services holding the introspection token
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+config.Get().IntrospectionToken)
+
+	resp, err := metrics.Client(metrics.UpstreamProvisioner).Do(req)
+	if err != nil {
+		return introspection{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return introspection{}, fmt.Errorf("token introspection failed with status %d", resp.StatusCode)
+	}
+
+	result := introspection{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return introspection{}, err
+	}
+
+	if result.Active {
+		cacheResult(key, result, time.Now())
+	}
+	return result, nil
+}
+
+func cachedResult(key string, now time.Time) (introspection, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cached, ok := cache[key]
+	if !ok || !now.Before(cached.expiresAt) {
+		return introspection{}, false
+	}
+	return cached.result, true
+}
+
+func cacheResult(key string, result introspection, now time.Time) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if len(cache) >= maxCachedIntrospections {
+		return
+	}
+	cache[key] = cachedIntrospection{result: result, expiresAt: now.Add(introspectionTTL)}
+}
+
+func sweepIntrospections(now time.Time) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	for key, cached := range cache {
+		if !now.Before(cached.expiresAt) {
+			delete(cache, key)
+		}
+	}
+}
+
+// namespaceFromPath returns the namespace of a /api/v1/<resource>/<namespace> route
+func namespaceFromPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[3]
+}
+
+func namespaceInProject(ctx context.Context, namespace string, projectId string) (bool, error) {
+	if namespace == "" {
+		return false, nil
+	}
+	ns, err := auth.MyClientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return ns.Annotations[projectIdLabel] == projectId, nil
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/Creometry/resources-service/apperror"
+	"github.com/Creometry/resources-service/metrics"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics counts and times the requests per route pattern, so that the ids
+// in the paths do not end up in the labels
+func Metrics(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	// the returned errors are only rendered by the error handler afterwards
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apperror.From(err).Status
+	}
+	metrics.ObserveRequest(c.Method(), c.Route().Path, status, time.Since(start))
+	return err
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/Creometry/resources-service/apperror"
+	"github.com/Creometry/resources-service/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Recover turns a panic of a handler into a 500 response instead of letting
+// it kill the process, and logs its stack trace
+func Recover(c *fiber.Ctx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.FromContext(c.UserContext()).Error("panic", "method", c.Method(), "path", c.Path(), "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			err = apperror.New(apperror.CodeInternal, "internal server error")
+		}
+	}()
+	return c.Next()
+}
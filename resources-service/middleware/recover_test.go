@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Creometry/resources-service/apperror"
+	"github.com/Creometry/resources-service/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRecoverReturnsInternalError(t *testing.T) {
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name  string
+		panic func()
+	}{
+		{name: "string", panic: func() { panic("boom") }},
+		{name: "error", panic: func() { panic(errors.New("boom")) }},
+		{name: "nil map", panic: func() {
+			var m map[string]string
+			m["key"] = "value"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler})
+			app.Use(Recover)
+			app.Get("/panic", func(c *fiber.Ctx) error {
+				tt.panic()
+				return nil
+			})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusInternalServerError {
+				t.Fatalf("status is %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+			}
+			body := struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			}{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("body is not an error document: %v", err)
+			}
+			if body.Code != string(apperror.CodeInternal) || body.Error == "" {
+				t.Fatalf("body is %+v, want the %s error", body, apperror.CodeInternal)
+			}
+		})
+	}
+}
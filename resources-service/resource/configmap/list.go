@@ -8,18 +8,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetConfigMaps(namespace string) ([]v1.ConfigMap, error) {
+func GetConfigMaps(ctx context.Context, namespace string) ([]v1.ConfigMap, error) {
 
-	configMapsClient := auth.MyInClusterClientSet.CoreV1().ConfigMaps(namespace)
-	list, err := configMapsClient.List(context.TODO(), metav1.ListOptions{})
+	configMapsClient := auth.MyClientSet.CoreV1().ConfigMaps(namespace)
+	list, err := configMapsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetConfigMap(namespace string, configMapName string) (v1.ConfigMap, error) {
+func GetConfigMap(ctx context.Context, namespace string, configMapName string) (v1.ConfigMap, error) {
 
-	configMapsClient := auth.MyInClusterClientSet.CoreV1().ConfigMaps(namespace)
-	configMap, err := configMapsClient.Get(context.TODO(), configMapName, metav1.GetOptions{})
+	configMapsClient := auth.MyClientSet.CoreV1().ConfigMaps(namespace)
+	configMap, err := configMapsClient.Get(ctx, configMapName, metav1.GetOptions{})
 	return *configMap, err
 
 }
@@ -8,18 +8,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetCronJobs(namespace string) ([]batchv1.CronJob, error) {
+func GetCronJobs(ctx context.Context, namespace string) ([]batchv1.CronJob, error) {
 
-	cronJobsClient := auth.MyInClusterClientSet.BatchV1().CronJobs(namespace)
-	list, err := cronJobsClient.List(context.TODO(), metav1.ListOptions{})
+	cronJobsClient := auth.MyClientSet.BatchV1().CronJobs(namespace)
+	list, err := cronJobsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetCronJob(namespace string, cronJobName string) (batchv1.CronJob, error) {
+func GetCronJob(ctx context.Context, namespace string, cronJobName string) (batchv1.CronJob, error) {
 
-	cronJobsClient := auth.MyInClusterClientSet.BatchV1().CronJobs(namespace)
-	cronJob, err := cronJobsClient.Get(context.TODO(), cronJobName, metav1.GetOptions{})
+	cronJobsClient := auth.MyClientSet.BatchV1().CronJobs(namespace)
+	cronJob, err := cronJobsClient.Get(ctx, cronJobName, metav1.GetOptions{})
 	return *cronJob, err
 
 }
@@ -9,10 +9,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetCustomResources(namespace string) ([]interface{}, error) {
+func GetCustomResources(ctx context.Context, namespace string) ([]interface{}, error) {
 	crdClient := auth.MyExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions()
 
-	list, err := crdClient.List(context.TODO(), metav1.ListOptions{})
+	list, err := crdClient.List(ctx, metav1.ListOptions{})
 	var resList []interface{}
 	var element interface{}
 
@@ -23,7 +23,7 @@ func GetCustomResources(namespace string) ([]interface{}, error) {
 			return nil, err
 		}
 
-		raw, err := restClient.Get().NamespaceIfScoped(namespace, crd.Spec.Scope == apiextensionsv1.NamespaceScoped).Resource(crd.Spec.Names.Plural).Do(context.TODO()).Raw()
+		raw, err := restClient.Get().NamespaceIfScoped(namespace, crd.Spec.Scope == apiextensionsv1.NamespaceScoped).Resource(crd.Spec.Names.Plural).Do(ctx).Raw()
 
 		if err != nil {
 			return nil, err
@@ -45,10 +45,10 @@ func GetCustomResources(namespace string) ([]interface{}, error) {
 	return resList, err
 }
 
-func GetCustomResource(namespace string, crdName string) (interface{}, error) {
+func GetCustomResource(ctx context.Context, namespace string, crdName string) (interface{}, error) {
 	crdClient := auth.MyExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions()
 
-	crd, err := crdClient.Get(context.TODO(), crdName, metav1.GetOptions{})
+	crd, err := crdClient.Get(ctx, crdName, metav1.GetOptions{})
 	var element interface{}
 
 	restClient, err := NewRESTClient(auth.Config, crd)
@@ -56,7 +56,7 @@ func GetCustomResource(namespace string, crdName string) (interface{}, error) {
 		return nil, err
 	}
 
-	raw, err := restClient.Get().NamespaceIfScoped(namespace, crd.Spec.Scope == apiextensionsv1.NamespaceScoped).Resource(crd.Spec.Names.Plural).Do(context.TODO()).Raw()
+	raw, err := restClient.Get().NamespaceIfScoped(namespace, crd.Spec.Scope == apiextensionsv1.NamespaceScoped).Resource(crd.Spec.Names.Plural).Do(ctx).Raw()
 
 	if err != nil {
 		return nil, err
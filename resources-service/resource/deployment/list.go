@@ -8,18 +8,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetDeployments(namespace string) ([]appsv1.Deployment, error) {
+func GetDeployments(ctx context.Context, namespace string) ([]appsv1.Deployment, error) {
 
-	deploymentsClient := auth.MyInClusterClientSet.AppsV1().Deployments(namespace)
-	list, err := deploymentsClient.List(context.TODO(), metav1.ListOptions{})
+	deploymentsClient := auth.MyClientSet.AppsV1().Deployments(namespace)
+	list, err := deploymentsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetDeployment(namespace string, deploymentName string) (appsv1.Deployment, error) {
+func GetDeployment(ctx context.Context, namespace string, deploymentName string) (appsv1.Deployment, error) {
 
-	deploymentsClient := auth.MyInClusterClientSet.AppsV1().Deployments(namespace)
-	deployment, err := deploymentsClient.Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	deploymentsClient := auth.MyClientSet.AppsV1().Deployments(namespace)
+	deployment, err := deploymentsClient.Get(ctx, deploymentName, metav1.GetOptions{})
 	return *deployment, err
 
 }
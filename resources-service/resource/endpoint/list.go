@@ -9,18 +9,18 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
-func GetEndpoints(namespace string) ([]v1.Endpoints, error) {
+func GetEndpoints(ctx context.Context, namespace string) ([]v1.Endpoints, error) {
 
-	endpointsClient := auth.MyInClusterClientSet.CoreV1().Endpoints(namespace)
-	list, err := endpointsClient.List(context.TODO(), metav1.ListOptions{})
+	endpointsClient := auth.MyClientSet.CoreV1().Endpoints(namespace)
+	list, err := endpointsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetEndpoint(namespace string, endpointName string) (v1.Endpoints, error) {
+func GetEndpoint(ctx context.Context, namespace string, endpointName string) (v1.Endpoints, error) {
 
-	endpointsClient := auth.MyInClusterClientSet.CoreV1().Endpoints(namespace)
-	endpoint, err := endpointsClient.Get(context.TODO(), endpointName, metav1.GetOptions{})
+	endpointsClient := auth.MyClientSet.CoreV1().Endpoints(namespace)
+	endpoint, err := endpointsClient.Get(ctx, endpointName, metav1.GetOptions{})
 	return *endpoint, err
 
 }
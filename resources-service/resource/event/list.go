@@ -8,10 +8,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetEvents(namespace string) ([]v1.Event, error) {
+func GetEvents(ctx context.Context, namespace string) ([]v1.Event, error) {
 
-	eventsClient := auth.MyInClusterClientSet.CoreV1().Events(namespace)
-	list, err := eventsClient.List(context.TODO(), metav1.ListOptions{})
+	eventsClient := auth.MyClientSet.CoreV1().Events(namespace)
+	list, err := eventsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
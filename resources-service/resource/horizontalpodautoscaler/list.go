@@ -8,18 +8,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetHorizontalPodAutoscalers(namespace string) ([]autoscaling.HorizontalPodAutoscaler, error) {
+func GetHorizontalPodAutoscalers(ctx context.Context, namespace string) ([]autoscaling.HorizontalPodAutoscaler, error) {
 
-	horizontalPodAutoscalersClient := auth.MyInClusterClientSet.AutoscalingV1().HorizontalPodAutoscalers(namespace)
-	list, err := horizontalPodAutoscalersClient.List(context.TODO(), metav1.ListOptions{})
+	horizontalPodAutoscalersClient := auth.MyClientSet.AutoscalingV1().HorizontalPodAutoscalers(namespace)
+	list, err := horizontalPodAutoscalersClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetHorizontalPodAutoscaler(namespace string, name string) (autoscaling.HorizontalPodAutoscaler, error) {
+func GetHorizontalPodAutoscaler(ctx context.Context, namespace string, name string) (autoscaling.HorizontalPodAutoscaler, error) {
 
-	horizontalPodAutoscalersClient := auth.MyInClusterClientSet.AutoscalingV1().HorizontalPodAutoscalers(namespace)
-	hpo, err := horizontalPodAutoscalersClient.Get(context.TODO(), name, metav1.GetOptions{})
+	horizontalPodAutoscalersClient := auth.MyClientSet.AutoscalingV1().HorizontalPodAutoscalers(namespace)
+	hpo, err := horizontalPodAutoscalersClient.Get(ctx, name, metav1.GetOptions{})
 	return *hpo, err
 
 }
@@ -8,16 +8,16 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetIngresses(namespace string) ([]v1.Ingress, error) {
+func GetIngresses(ctx context.Context, namespace string) ([]v1.Ingress, error) {
 
-	list, err := auth.MyInClusterClientSet.NetworkingV1().Ingresses(namespace).List(context.TODO(), metav1.ListOptions{})
+	list, err := auth.MyClientSet.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetIngress(namespace string, ingressName string) (v1.Ingress, error) {
+func GetIngress(ctx context.Context, namespace string, ingressName string) (v1.Ingress, error) {
 
-	ingress, err := auth.MyInClusterClientSet.NetworkingV1().Ingresses(namespace).Get(context.TODO(), ingressName, metav1.GetOptions{})
+	ingress, err := auth.MyClientSet.NetworkingV1().Ingresses(namespace).Get(ctx, ingressName, metav1.GetOptions{})
 	return *ingress, err
 
 }
@@ -9,18 +9,18 @@ import (
 	"github.com/Creometry/resources-service/auth"
 )
 
-func GetJobs(namespace string) ([]batchv1.Job, error) {
+func GetJobs(ctx context.Context, namespace string) ([]batchv1.Job, error) {
 
-	jobsClient := auth.MyInClusterClientSet.BatchV1().Jobs(namespace)
-	list, err := jobsClient.List(context.TODO(), metav1.ListOptions{})
+	jobsClient := auth.MyClientSet.BatchV1().Jobs(namespace)
+	list, err := jobsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetJob(namespace string, jobName string) (batchv1.Job, error) {
+func GetJob(ctx context.Context, namespace string, jobName string) (batchv1.Job, error) {
 
-	jobsClient := auth.MyInClusterClientSet.BatchV1().Jobs(namespace)
-	job, err := jobsClient.Get(context.TODO(), jobName, metav1.GetOptions{})
+	jobsClient := auth.MyClientSet.BatchV1().Jobs(namespace)
+	job, err := jobsClient.Get(ctx, jobName, metav1.GetOptions{})
 	return *job, err
 
 }
@@ -9,18 +9,18 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
-func GetPersistentVolumeClaims(namespace string) ([]v1.PersistentVolumeClaim, error) {
+func GetPersistentVolumeClaims(ctx context.Context, namespace string) ([]v1.PersistentVolumeClaim, error) {
 
-	pvcClient := auth.MyInClusterClientSet.CoreV1().PersistentVolumeClaims(namespace)
-	list, err := pvcClient.List(context.TODO(), metav1.ListOptions{})
+	pvcClient := auth.MyClientSet.CoreV1().PersistentVolumeClaims(namespace)
+	list, err := pvcClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetPersistentVolumeClaim(namespace string, pvcName string) (v1.PersistentVolumeClaim, error) {
+func GetPersistentVolumeClaim(ctx context.Context, namespace string, pvcName string) (v1.PersistentVolumeClaim, error) {
 
-	pvcClient := auth.MyInClusterClientSet.CoreV1().PersistentVolumeClaims(namespace)
-	pvc, err := pvcClient.Get(context.TODO(), pvcName, metav1.GetOptions{})
+	pvcClient := auth.MyClientSet.CoreV1().PersistentVolumeClaims(namespace)
+	pvc, err := pvcClient.Get(ctx, pvcName, metav1.GetOptions{})
 	return *pvc, err
 
 }
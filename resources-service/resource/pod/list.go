@@ -8,18 +8,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func GetPods(namespace string) ([]v1.Pod, error) {
+func GetPods(ctx context.Context, namespace string) ([]v1.Pod, error) {
 
-	podsClient := auth.MyInClusterClientSet.CoreV1().Pods(namespace)
-	pods, err := podsClient.List(context.TODO(), metav1.ListOptions{})
+	podsClient := auth.MyClientSet.CoreV1().Pods(namespace)
+	pods, err := podsClient.List(ctx, metav1.ListOptions{})
 	return pods.Items, err
 
 }
 
-func GetPod(namespace string, podName string) (v1.Pod, error) {
+func GetPod(ctx context.Context, namespace string, podName string) (v1.Pod, error) {
 
-	podsClient := auth.MyInClusterClientSet.CoreV1().Pods(namespace)
-	pod, err := podsClient.Get(context.TODO(), podName, metav1.GetOptions{})
+	podsClient := auth.MyClientSet.CoreV1().Pods(namespace)
+	pod, err := podsClient.Get(ctx, podName, metav1.GetOptions{})
 	return *pod, err
 
 }
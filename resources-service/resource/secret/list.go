@@ -9,18 +9,18 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
-func GetSecrets(namespace string) ([]v1.Secret, error) {
+func GetSecrets(ctx context.Context, namespace string) ([]v1.Secret, error) {
 
-	secretsClient := auth.MyInClusterClientSet.CoreV1().Secrets(namespace)
-	list, err := secretsClient.List(context.TODO(), metav1.ListOptions{})
+	secretsClient := auth.MyClientSet.CoreV1().Secrets(namespace)
+	list, err := secretsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetSecret(namespace string, secretName string) (v1.Secret, error) {
+func GetSecret(ctx context.Context, namespace string, secretName string) (v1.Secret, error) {
 
-	secretsClient := auth.MyInClusterClientSet.CoreV1().Secrets(namespace)
-	secret, err := secretsClient.Get(context.TODO(), secretName, metav1.GetOptions{})
+	secretsClient := auth.MyClientSet.CoreV1().Secrets(namespace)
+	secret, err := secretsClient.Get(ctx, secretName, metav1.GetOptions{})
 	return *secret, err
 
 }
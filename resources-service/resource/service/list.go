@@ -9,18 +9,18 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
-func GetServices(namespace string) ([]v1.Service, error) {
+func GetServices(ctx context.Context, namespace string) ([]v1.Service, error) {
 
-	servicesClient := auth.MyInClusterClientSet.CoreV1().Services(namespace)
-	services, err := servicesClient.List(context.TODO(), metav1.ListOptions{})
+	servicesClient := auth.MyClientSet.CoreV1().Services(namespace)
+	services, err := servicesClient.List(ctx, metav1.ListOptions{})
 	return services.Items, err
 
 }
 
-func GetService(namespace string, serviceName string) (v1.Service, error) {
+func GetService(ctx context.Context, namespace string, serviceName string) (v1.Service, error) {
 
-	servicesClient := auth.MyInClusterClientSet.CoreV1().Services(namespace)
-	service, err := servicesClient.Get(context.TODO(), serviceName, metav1.GetOptions{})
+	servicesClient := auth.MyClientSet.CoreV1().Services(namespace)
+	service, err := servicesClient.Get(ctx, serviceName, metav1.GetOptions{})
 	return *service, err
 
 }
@@ -9,20 +9,20 @@ import (
 	"github.com/Creometry/resources-service/auth"
 )
 
-func GetStatefulSets(namespace string) ([]appsv1.StatefulSet, error) {
+func GetStatefulSets(ctx context.Context, namespace string) ([]appsv1.StatefulSet, error) {
 
-	statefulSetsClient := auth.MyInClusterClientSet.AppsV1().StatefulSets(namespace)
+	statefulSetsClient := auth.MyClientSet.AppsV1().StatefulSets(namespace)
 
-	list, err := statefulSetsClient.List(context.TODO(), metav1.ListOptions{})
+	list, err := statefulSetsClient.List(ctx, metav1.ListOptions{})
 	return list.Items, err
 
 }
 
-func GetStatefulSet(namespace string, statefulSetName string) (appsv1.StatefulSet, error) {
+func GetStatefulSet(ctx context.Context, namespace string, statefulSetName string) (appsv1.StatefulSet, error) {
 
-	statefulSetsClient := auth.MyInClusterClientSet.AppsV1().StatefulSets(namespace)
+	statefulSetsClient := auth.MyClientSet.AppsV1().StatefulSets(namespace)
 
-	statefulSet, err := statefulSetsClient.Get(context.TODO(), statefulSetName, metav1.GetOptions{})
+	statefulSet, err := statefulSetsClient.Get(ctx, statefulSetName, metav1.GetOptions{})
 	return *statefulSet, err
 
 }
@@ -2,13 +2,21 @@ package routes
 
 import (
 	l "github.com/Creometry/resources-service/controllers/list"
+	"github.com/Creometry/resources-service/controllers/probe"
+	"github.com/Creometry/resources-service/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func CreateRoutes(app *fiber.App) {
 
-	v1 := app.Group("/api/v1")
+	// probes, outside of the api so that they need no authentication
+	app.Get("/healthz", probe.Healthz)
+	app.Get("/readyz", probe.Readyz)
+	app.Get("/status", probe.GetStatus)
+	app.Get("/metrics", probe.GetMetrics)
+
+	v1 := app.Group("/api/v1", middleware.Authenticate)
 	v1.Get("/pods/:namespace", l.GetAllPods)
 	v1.Get("/pods/:namespace/:pod", l.GetPod)
 	v1.Get("/services/:namespace", l.GetAllServices)
@@ -0,0 +1,43 @@
+#!/usr/bin/env bash
+
+# go-provisioner and resources-service are built from their own directory and
+# cannot import each other, the packages below are copied between them. The
+# copies must only differ by their import paths, a fix made in one service
+# is made in the other in the same change.
+
+root="$(cd "$(dirname "$0")/.." && pwd)"
+
+shared=(
+        "apperror/apperror.go apperror/apperror.go"
+        "logger/logger.go logger/logger.go"
+        "logger/redact.go logger/redact.go"
+        "middleware/recover.go middleware/recover.go"
+        "middleware/recover_test.go middleware/recover_test.go"
+        "middleware/requestid.go middleware/requestid.go"
+        "middleware/tracing.go middleware/tracing.go"
+        "middleware/metrics.go middleware/metrics.go"
+)
+
+# normalize rewrites the import paths of both modules to the same prefix
+normalize() {
+        sed -e 's#github.com/Creometry/dashboard/go-provisioner/internal/#module/#g' \
+                -e 's#github.com/Creometry/dashboard/go-provisioner/#module/#g' \
+                -e 's#github.com/Creometry/resources-service/#module/#g' "$1"
+}
+
+status=0
+for pair in "${shared[@]}"
+do
+        pair_split=($pair)
+        provisioner="$root/go-provisioner/${pair_split[0]}"
+        resources="$root/resources-service/${pair_split[1]}"
+        if ! diff -u --label "go-provisioner/${pair_split[0]}" --label "resources-service/${pair_split[1]}" \
+                <(normalize "$provisioner") <(normalize "$resources"); then
+                status=1
+        fi
+done
+
+if [ $status -ne 0 ]; then
+        echo 'The shared packages differ between go-provisioner and resources-service!'
+fi
+exit $status